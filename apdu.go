@@ -1,13 +1,52 @@
 package ezsignnfc
 
-import "fmt"
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// PIN is the 4-byte reference data checked by VERIFY.
+type PIN [4]byte
+
+// DefaultPIN is the factory PIN of EZ-Sign tags.
+var DefaultPIN = PIN{0x20, 0x09, 0x12, 0x10}
+
+// ParsePIN parses a PIN from 8 hex digits, e.g. "20091210".
+func ParsePIN(s string) (PIN, error) {
+	var pin PIN
+	b, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return pin, fmt.Errorf("invalid pin hex: %w", err)
+	}
+	if len(b) != len(pin) {
+		return pin, fmt.Errorf("pin must be %d bytes: got %d", len(pin), len(b))
+	}
+	copy(pin[:], b)
+	return pin, nil
+}
+
+func (p PIN) String() string {
+	return strings.ToUpper(hex.EncodeToString(p[:]))
+}
 
 var (
-	apduAuthenticate = []byte{0x00, 0x20, 0x00, 0x01, 0x04, 0x20, 0x09, 0x12, 0x10}
 	apduStartRefresh = []byte{0xF0, 0xD4, 0x85, 0x80, 0x00}
 	apduPollStatus   = []byte{0xF0, 0xDE, 0x00, 0x00, 0x01}
 )
 
+func buildVerifyAPDU(pin PIN) []byte {
+	apdu := []byte{0x00, 0x20, 0x00, 0x01, byte(len(pin))}
+	return append(apdu, pin[:]...)
+}
+
+// buildChangePINAPDU builds ISO 7816 CHANGE REFERENCE DATA with old||new.
+func buildChangePINAPDU(oldPIN, newPIN PIN) []byte {
+	apdu := []byte{0x00, 0x24, 0x00, 0x01, byte(len(oldPIN) + len(newPIN))}
+	apdu = append(apdu, oldPIN[:]...)
+	return append(apdu, newPIN[:]...)
+}
+
 func buildImageDataAPDU(blockNo int, fragNo int, payload []byte, isLast bool) ([]byte, error) {
 	if blockNo < 0 || blockNo > 0xFF {
 		return nil, fmt.Errorf("blockNo out of range: %d", blockNo)
@@ -29,3 +68,23 @@ func buildImageDataAPDU(blockNo int, fragNo int, payload []byte, isLast bool) ([
 	apdu = append(apdu, payload...)
 	return apdu, nil
 }
+
+func parseImageDataAPDU(apdu []byte) (blockNo int, fragNo int, payload []byte, isLast bool, err error) {
+	if len(apdu) < 7 {
+		return 0, 0, nil, false, fmt.Errorf("image apdu too short: %d", len(apdu))
+	}
+	if apdu[0] != 0xF0 || apdu[1] != 0xD3 {
+		return 0, 0, nil, false, fmt.Errorf("not an image apdu: %X", apdu[:2])
+	}
+	if int(apdu[4]) != len(apdu)-5 {
+		return 0, 0, nil, false, fmt.Errorf("image apdu lc mismatch: lc=%d body=%d", apdu[4], len(apdu)-5)
+	}
+	switch apdu[3] {
+	case 0x00:
+	case 0x01:
+		isLast = true
+	default:
+		return 0, 0, nil, false, fmt.Errorf("invalid image apdu p2: 0x%02X", apdu[3])
+	}
+	return int(apdu[5]), int(apdu[6]), apdu[7:], isLast, nil
+}
@@ -0,0 +1,218 @@
+package ezsignnfc
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"sync"
+	"time"
+)
+
+// Job is one write dispatched by a BatchWriter. Exactly one of Image and
+// Pixels must be set. A job with a Profile only goes to devices opened with
+// that profile; a zero Profile goes to any device and uses its profile.
+type Job struct {
+	ID      string
+	Profile Profile
+	Image   image.Image
+	Options ImageEncodeOptions
+	Pixels  []uint8
+}
+
+// JobResult reports the outcome of a Job.
+type JobResult struct {
+	Job     Job
+	Reader  string
+	Err     error
+	Elapsed time.Duration
+}
+
+// BatchWriter dispatches jobs to several devices concurrently. Each device
+// runs one job at a time; jobs go to whichever matching device is free first.
+type BatchWriter struct {
+	devices   []*Device
+	owned     bool
+	jobs      chan Job
+	byProfile map[Profile]chan Job
+	results   chan JobResult
+	wg        sync.WaitGroup
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewBatchWriter starts one worker per device. The caller keeps ownership
+// of the devices and must not use them until Close returns.
+func NewBatchWriter(ctx context.Context, devices []*Device) (*BatchWriter, error) {
+	if len(devices) == 0 {
+		return nil, fmt.Errorf("batch writer needs at least one device")
+	}
+	for i, d := range devices {
+		if d == nil {
+			return nil, fmt.Errorf("device %d must not be nil", i)
+		}
+	}
+	w := &BatchWriter{
+		devices:   devices,
+		jobs:      make(chan Job),
+		byProfile: make(map[Profile]chan Job),
+		results:   make(chan JobResult, len(devices)),
+	}
+	for _, d := range devices {
+		if _, ok := w.byProfile[d.profile]; !ok {
+			w.byProfile[d.profile] = make(chan Job)
+		}
+	}
+	for _, d := range devices {
+		w.wg.Add(1)
+		go w.work(ctx, d, w.byProfile[d.profile])
+	}
+	return w, nil
+}
+
+// OpenBatchWriter opens every reader from ListReaders for product and starts
+// a BatchWriter on them. Readers that cannot be opened (e.g. no card) are
+// skipped; it fails when none can be opened. Close also closes the devices.
+func OpenBatchWriter(ctx context.Context, product Product) (*BatchWriter, error) {
+	readers, err := ListReaders()
+	if err != nil {
+		return nil, err
+	}
+	var devices []*Device
+	var firstErr error
+	for _, r := range readers {
+		d, err := Open(product, ReaderName(r))
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		devices = append(devices, d)
+	}
+	if len(devices) == 0 {
+		if firstErr == nil {
+			firstErr = fmt.Errorf("no pc/sc readers found")
+		}
+		return nil, firstErr
+	}
+	w, err := NewBatchWriter(ctx, devices)
+	if err != nil {
+		for _, d := range devices {
+			d.Close()
+		}
+		return nil, err
+	}
+	w.owned = true
+	return w, nil
+}
+
+// Submit queues a job. It blocks until a device picks the job up, so
+// Results must be drained concurrently. A job whose Profile no device has
+// is rejected.
+func (w *BatchWriter) Submit(job Job) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return fmt.Errorf("batch writer closed")
+	}
+	queue := w.jobs
+	if job.Profile != (Profile{}) {
+		var ok bool
+		if queue, ok = w.byProfile[job.Profile]; !ok {
+			return fmt.Errorf("job %q: no device for profile %s (%dx%d, %dbpp)",
+				job.ID, job.Profile.Product, job.Profile.Width, job.Profile.Height, job.Profile.BitsPerPixel)
+		}
+	}
+	queue <- job
+	return nil
+}
+
+// Results returns the per-job results. It is closed by Close after all
+// submitted jobs have finished.
+func (w *BatchWriter) Results() <-chan JobResult {
+	return w.results
+}
+
+// Close stops accepting jobs, waits for running jobs and closes Results.
+// Devices opened by OpenBatchWriter are closed as well.
+func (w *BatchWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.jobs)
+	for _, queue := range w.byProfile {
+		close(queue)
+	}
+	w.mu.Unlock()
+
+	w.wg.Wait()
+	close(w.results)
+	if !w.owned {
+		return nil
+	}
+	var firstErr error
+	for _, d := range w.devices {
+		if err := d.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// work runs jobs for any device and jobs for d's profile until both queues
+// are closed.
+func (w *BatchWriter) work(ctx context.Context, d *Device, own chan Job) {
+	defer w.wg.Done()
+	anyJobs := w.jobs
+	for anyJobs != nil || own != nil {
+		var job Job
+		var ok bool
+		select {
+		case job, ok = <-anyJobs:
+			if !ok {
+				anyJobs = nil
+				continue
+			}
+		case job, ok = <-own:
+			if !ok {
+				own = nil
+				continue
+			}
+		}
+		start := time.Now()
+		err := ctx.Err()
+		if err == nil {
+			err = d.writeJob(ctx, job)
+		}
+		w.results <- JobResult{Job: job, Reader: d.ReaderName(), Err: err, Elapsed: time.Since(start)}
+	}
+}
+
+func (d *Device) writeJob(ctx context.Context, job Job) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	profile := d.profile
+	if job.Profile != (Profile{}) && job.Profile != profile {
+		return fmt.Errorf("job %q: profile %s does not match device profile %s", job.ID, job.Profile.Product, profile.Product)
+	}
+	var apdus [][]byte
+	var err error
+	switch {
+	case job.Image != nil && job.Pixels != nil:
+		return fmt.Errorf("job %q: set either image or pixels, not both", job.ID)
+	case job.Image != nil:
+		apdus, err = EncodeImageToAPDUsWithOptions(profile, job.Image, d.maxFragment, job.Options)
+	case job.Pixels != nil:
+		apdus, err = EncodePixelsToAPDUs(profile, job.Pixels, d.maxFragment)
+	default:
+		return fmt.Errorf("job %q: image or pixels required", job.ID)
+	}
+	if err != nil {
+		return err
+	}
+	return d.writeAPDUs(ctx, apdus, true)
+}
@@ -0,0 +1,105 @@
+package ezsignnfc
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"testing"
+)
+
+func TestBatchWriter(t *testing.T) {
+	quad := PresetProfiles[Product29Quad]
+	sims := []*Simulator{NewSimulator(quad), NewSimulator(quad), NewSimulator(quad)}
+	devices := make([]*Device, len(sims))
+	for i, sim := range sims {
+		dev, err := OpenTransport(quad, sim)
+		if err != nil {
+			t.Fatal(err)
+		}
+		devices[i] = dev
+	}
+
+	w, err := NewBatchWriter(context.Background(), devices)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 12
+	go func() {
+		for i := 0; i < n; i++ {
+			job := Job{ID: fmt.Sprintf("job-%d", i), Pixels: make([]uint8, quad.Width*quad.Height)}
+			switch i % 4 {
+			case 1:
+				job.Pixels = nil
+				job.Image = image.NewNRGBA(image.Rect(0, 0, 10, 10))
+			case 2:
+				job.Profile = quad
+				job.Pixels[0] = ColorRed
+			case 3:
+				job.Pixels = nil
+			}
+			if err := w.Submit(job); err != nil {
+				t.Error(err)
+			}
+		}
+		w.Close()
+	}()
+
+	ok, failed := 0, 0
+	for res := range w.Results() {
+		if res.Reader == "" {
+			t.Fatalf("%s: missing reader name", res.Job.ID)
+		}
+		if res.Err != nil {
+			failed++
+			continue
+		}
+		ok++
+	}
+	if ok != 9 || failed != 3 {
+		t.Fatalf("results: ok=%d failed=%d want 9/3", ok, failed)
+	}
+	refreshes := 0
+	for _, sim := range sims {
+		refreshes += sim.Refreshes()
+	}
+	if refreshes != 9 {
+		t.Fatalf("total refreshes: got %d want 9", refreshes)
+	}
+	if err := w.Submit(Job{}); err == nil {
+		t.Fatal("expected error submitting after Close")
+	}
+}
+
+func TestBatchWriterRoutesByProfile(t *testing.T) {
+	small, large := PresetProfiles[Product29Quad], PresetProfiles[Product42Quad]
+	smallSim, largeSim := NewSimulator(small), NewSimulator(large)
+	smallDev, _ := OpenTransport(small, smallSim)
+	largeDev, _ := OpenTransport(large, largeSim)
+	w, err := NewBatchWriter(context.Background(), []*Device{smallDev, largeDev})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Submit(Job{ID: "mono", Profile: PresetProfiles[Product29Mono], Pixels: []uint8{0}}); err == nil {
+		t.Fatal("expected a job without a matching device to be rejected")
+	}
+	const n = 6
+	go func() {
+		for i := 0; i < n; i++ {
+			job := Job{ID: fmt.Sprintf("large-%d", i), Profile: large, Pixels: make([]uint8, large.Width*large.Height)}
+			if err := w.Submit(job); err != nil {
+				t.Error(err)
+			}
+		}
+		w.Close()
+	}()
+	for res := range w.Results() {
+		if res.Err != nil {
+			t.Fatalf("%s: %v", res.Job.ID, res.Err)
+		}
+	}
+	if smallSim.Refreshes() != 0 || largeSim.Refreshes() != n {
+		t.Fatalf("refreshes: 2.9 %d, 4.2 %d", smallSim.Refreshes(), largeSim.Refreshes())
+	}
+}
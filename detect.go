@@ -0,0 +1,102 @@
+package ezsignnfc
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// ProductRule maps tag identity to a product. ATR and UID are prefixes;
+// an empty prefix matches anything.
+//
+// EZ-Sign tags do not report their panel variant in a documented way, so the
+// package ships no rules: detection needs the ATR/UID prefixes used by your
+// fleet, passed to DetectProduct, OpenAuto or OpenTransportAuto.
+type ProductRule struct {
+	ATR     []byte
+	UID     []byte
+	Product Product
+}
+
+// DetectProduct picks the product whose rule matches atr and uid. It fails
+// with ErrUnknownProduct when no rule or rules for several products match.
+func DetectProduct(rules []ProductRule, atr, uid []byte) (Product, error) {
+	var found Product
+	for _, rule := range rules {
+		if len(rule.ATR) == 0 && len(rule.UID) == 0 {
+			continue
+		}
+		if !bytes.HasPrefix(atr, rule.ATR) || !bytes.HasPrefix(uid, rule.UID) {
+			continue
+		}
+		if found != "" && found != rule.Product {
+			return "", fmt.Errorf("%w: atr %X uid %X matches both %q and %q", ErrUnknownProduct, atr, uid, found, rule.Product)
+		}
+		found = rule.Product
+	}
+	if found == "" {
+		return "", fmt.Errorf("%w: atr %X uid %X", ErrUnknownProduct, atr, uid)
+	}
+	if _, ok := PresetProfiles[found]; !ok {
+		return "", fmt.Errorf("%w: rule names unknown product %q", ErrUnknownProduct, found)
+	}
+	return found, nil
+}
+
+// OpenAuto opens a reader like Open and detects the product from the tag
+// with rules.
+func OpenAuto(rules []ProductRule, opts ...Option) (*Device, error) {
+	if err := checkProductRules(rules); err != nil {
+		return nil, err
+	}
+	cfg, err := newOpenConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	t, err := cfg.openTransport()
+	if err != nil {
+		return nil, err
+	}
+	d, err := OpenTransportAuto(rules, t)
+	if err != nil {
+		return nil, err
+	}
+	cfg.configure(d)
+	return d, nil
+}
+
+// OpenTransportAuto opens a device on transport and detects its product
+// with rules. The transport is closed when detection fails.
+func OpenTransportAuto(rules []ProductRule, transport Transport) (*Device, error) {
+	if transport == nil {
+		return nil, fmt.Errorf("transport must not be nil")
+	}
+	if err := checkProductRules(rules); err != nil {
+		transport.Close()
+		return nil, err
+	}
+	product, err := detectTransportProduct(rules, transport)
+	if err != nil {
+		transport.Close()
+		return nil, err
+	}
+	return newDevice(PresetProfiles[product], transport), nil
+}
+
+func checkProductRules(rules []ProductRule) error {
+	if len(rules) == 0 {
+		return fmt.Errorf("product rules must not be empty")
+	}
+	return nil
+}
+
+func detectTransportProduct(rules []ProductRule, t Transport) (Product, error) {
+	atr, err := transportATR(t)
+	if err != nil {
+		return "", err
+	}
+	uid, err := transportUID(t)
+	if err != nil {
+		return "", err
+	}
+	return DetectProduct(rules, atr, uid)
+}
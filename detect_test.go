@@ -0,0 +1,66 @@
+package ezsignnfc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDetectProduct(t *testing.T) {
+	rules := []ProductRule{
+		{UID: []byte{0x04, 0x5A}, Product: Product42Quad},
+		{UID: []byte{0x04, 0x11}, Product: Product29Mono},
+		{ATR: []byte{0x3B, 0x8F}, UID: []byte{0x04, 0x22}, Product: Product29Quad},
+		{UID: []byte{0x04, 0x33}, Product: Product42Mono},
+		{ATR: []byte{0x3B, 0x81}, Product: Product29Mono},
+	}
+
+	tests := []struct {
+		name string
+		atr  []byte
+		uid  []byte
+		want Product
+		err  bool
+	}{
+		{"uid-prefix", nil, []byte{0x04, 0x5A, 0x01}, Product42Quad, false},
+		{"atr-and-uid", []byte{0x3B, 0x8F, 0x80}, []byte{0x04, 0x22, 0x99}, Product29Quad, false},
+		{"atr-mismatch", []byte{0x3B, 0x80}, []byte{0x04, 0x22, 0x99}, "", true},
+		{"unknown", []byte{0x3B, 0x80}, []byte{0x08, 0x00}, "", true},
+		{"ambiguous", []byte{0x3B, 0x81}, []byte{0x04, 0x33}, "", true},
+	}
+	for _, tc := range tests {
+		got, err := DetectProduct(rules, tc.atr, tc.uid)
+		if tc.err {
+			if !errors.Is(err, ErrUnknownProduct) {
+				t.Fatalf("%s: expected ErrUnknownProduct, got %v", tc.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got != tc.want {
+			t.Fatalf("%s: got %q want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestOpenTransportAuto(t *testing.T) {
+	rules := []ProductRule{{UID: []byte{0x04, 0x5A}, Product: Product29Quad}}
+
+	sim := NewSimulator(PresetProfiles[Product29Quad])
+	dev, err := OpenTransportAuto(rules, sim)
+	if err != nil {
+		t.Fatalf("OpenTransportAuto: %v", err)
+	}
+	if dev.profile.Product != Product29Quad {
+		t.Fatalf("detected product: got %q", dev.profile.Product)
+	}
+
+	sim.SetIdentity([]byte{0x04, 0x00, 0x00, 0x00}, nil)
+	if _, err := OpenTransportAuto(rules, sim); !errors.Is(err, ErrUnknownProduct) {
+		t.Fatalf("expected ErrUnknownProduct, got %v", err)
+	}
+	if _, err := OpenTransportAuto(nil, NewSimulator(PresetProfiles[Product29Quad])); err == nil {
+		t.Fatal("expected error without rules")
+	}
+}
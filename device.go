@@ -1,28 +1,44 @@
 package ezsignnfc
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"image"
+	"io"
+	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/ebfe/scard"
 )
 
-// Device is an active PC/SC connection to EZ-Sign.
+// Device is an active connection to EZ-Sign over a Transport.
+// It is safe for concurrent use; operations are serialized.
 type Device struct {
-	ctx            *scard.Context
-	card           *scard.Card
-	reader         string
-	profile        Profile
-	maxFragment    int
-	pollInterval   time.Duration
-	maxPollAttempt int
+	mu            sync.Mutex
+	transport     Transport
+	reader        string
+	profile       Profile
+	pin           PIN
+	uid           []byte
+	maxFragment   int
+	pollStrategy  PollStrategy
+	lastRefresh   time.Duration
+	retryBudget   int
+	autoReconnect bool
+	progress      func(Progress)
+	logger        *slog.Logger
+	apduTimeout   time.Duration
+	opTimeout     time.Duration
 }
 
 // ReaderSelector chooses one reader from detected PC/SC readers.
+// Selectors can be passed to Open directly as options.
 type ReaderSelector interface {
-	selectReader(readers []string) (string, error)
+	Option
+	selectReader(readers []readerState) (string, error)
 }
 
 type readerIndexSelector int
@@ -38,22 +54,22 @@ func ReaderName(name string) ReaderSelector {
 	return readerNameSelector(name)
 }
 
-func (s readerIndexSelector) selectReader(readers []string) (string, error) {
+func (s readerIndexSelector) selectReader(readers []readerState) (string, error) {
 	idx := int(s)
 	if idx < 0 || idx >= len(readers) {
 		return "", fmt.Errorf("reader index out of range: %d (readers=%d)", idx, len(readers))
 	}
-	return readers[idx], nil
+	return readers[idx].Name, nil
 }
 
-func (s readerNameSelector) selectReader(readers []string) (string, error) {
+func (s readerNameSelector) selectReader(readers []readerState) (string, error) {
 	name := string(s)
 	if name == "" {
 		return "", fmt.Errorf("reader name must not be empty")
 	}
 	for _, r := range readers {
-		if r == name {
-			return r, nil
+		if r.Name == name {
+			return r.Name, nil
 		}
 	}
 	return "", fmt.Errorf("reader name not found: %q", name)
@@ -75,12 +91,41 @@ func ListReaders() ([]string, error) {
 }
 
 // Open opens a device for a preset product profile.
-// When no selector is provided, it opens the first available reader.
-func Open(product Product, selectors ...ReaderSelector) (*Device, error) {
+// When no reader is selected, it opens the first available reader.
+func Open(product Product, opts ...Option) (*Device, error) {
 	profile, err := ProfileByProduct(product)
 	if err != nil {
 		return nil, err
 	}
+	cfg, err := newOpenConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	t, err := cfg.openTransport()
+	if err != nil {
+		return nil, err
+	}
+	d := newDevice(profile, t)
+	cfg.configure(d)
+	return d, nil
+}
+
+// OpenReader connects to a card on a PC/SC reader and returns it as a bare
+// Transport, e.g. to serve it with a RelayServer. Only reader, share mode and
+// protocol options are accepted.
+func OpenReader(opts ...Option) (Transport, error) {
+	cfg, err := newOpenConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.requireReaderOnly("OpenReader"); err != nil {
+		return nil, err
+	}
+	return openSCardTransport(cfg)
+}
+
+// openSCardTransport connects to the card on the PC/SC reader chosen by cfg.
+func openSCardTransport(cfg *openConfig) (*scardTransport, error) {
 	ctx, err := scard.EstablishContext()
 	if err != nil {
 		return nil, fmt.Errorf("establish pc/sc context: %w", err)
@@ -96,81 +141,277 @@ func Open(product Product, selectors ...ReaderSelector) (*Device, error) {
 		return nil, fmt.Errorf("no pc/sc readers found")
 	}
 
-	reader, err := resolveReader(readers, selectors)
+	states, err := queryReaderStates(ctx, readers)
 	if err != nil {
 		ctx.Release()
 		return nil, err
 	}
+	reader, err := resolveReaderState(states, cfg.selectors)
+	if err != nil {
+		ctx.Release()
+		return nil, err
+	}
+
+	return connectSCardTransport(ctx, reader, cfg)
+}
 
-	card, err := ctx.Connect(reader, scard.ShareShared, scard.ProtocolAny)
+// connectSCardTransport connects to a card on reader and hands ctx over to
+// the returned transport. ctx is released on failure.
+func connectSCardTransport(ctx *scard.Context, reader string, cfg *openConfig) (*scardTransport, error) {
+	card, err := ctx.Connect(reader, cfg.share.scard(), cfg.protocol.scard())
 	if err != nil {
 		ctx.Release()
 		return nil, fmt.Errorf("connect reader %q: %w", reader, err)
 	}
+	return &scardTransport{ctx: ctx, card: card, reader: reader, share: cfg.share, protocol: cfg.protocol}, nil
+}
 
+// OpenTransport opens a device for a profile on top of an arbitrary Transport.
+// The device takes ownership of the transport and closes it on Close.
+// Reader, share mode, protocol and transport options are rejected.
+func OpenTransport(profile Profile, transport Transport, opts ...Option) (*Device, error) {
+	if transport == nil {
+		return nil, fmt.Errorf("transport must not be nil")
+	}
+	cfg, err := newOpenConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.requireTransport("OpenTransport"); err != nil {
+		return nil, err
+	}
+	if profile.Width <= 0 || profile.Height <= 0 {
+		return nil, fmt.Errorf("invalid profile size: %dx%d", profile.Width, profile.Height)
+	}
+	if profile.BitsPerPixel != 1 && profile.BitsPerPixel != 2 {
+		return nil, fmt.Errorf("unsupported bits per pixel: %d", profile.BitsPerPixel)
+	}
+	d := newDevice(profile, transport)
+	cfg.configure(d)
+	return d, nil
+}
+
+func newDevice(profile Profile, transport Transport) *Device {
 	return &Device{
-		ctx:            ctx,
-		card:           card,
-		reader:         reader,
-		profile:        profile,
-		maxFragment:    250,
-		pollInterval:   500 * time.Millisecond,
-		maxPollAttempt: 60,
-	}, nil
+		transport:    transport,
+		reader:       transport.ReaderName(),
+		profile:      profile,
+		pin:          DefaultPIN,
+		maxFragment:  250,
+		pollStrategy: fixedPoll{interval: 500 * time.Millisecond, attempts: 60},
+		logger:       slog.New(discardHandler{}),
+	}
 }
 
-func resolveReader(readers []string, selectors []ReaderSelector) (string, error) {
+func resolveReaderState(readers []readerState, selectors []ReaderSelector) (string, error) {
+	if err := checkSelectors(selectors); err != nil {
+		return "", err
+	}
 	if len(selectors) == 0 {
-		return readers[0], nil
+		return readers[0].Name, nil
 	}
-	if len(selectors) != 1 {
-		return "", fmt.Errorf("open accepts at most one reader selector")
+	return selectors[0].selectReader(readers)
+}
+
+func checkSelectors(selectors []ReaderSelector) error {
+	if len(selectors) > 1 {
+		return fmt.Errorf("open accepts at most one reader selector")
 	}
-	if selectors[0] == nil {
-		return "", fmt.Errorf("reader selector must not be nil")
+	if len(selectors) == 1 && selectors[0] == nil {
+		return fmt.Errorf("reader selector must not be nil")
 	}
-	return selectors[0].selectReader(readers)
+	return nil
 }
 
 func (d *Device) ReaderName() string {
 	return d.reader
 }
 
+// UID returns the NFC UID of the tag, read with the PC/SC GET DATA
+// pseudo-APDU (FF CA 00 00 00). The value is cached until a reconnect.
+func (d *Device) UID(ctx context.Context) ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.uidLocked(ctx)
+}
+
+func (d *Device) uidLocked(ctx context.Context) ([]byte, error) {
+	if d.uid != nil {
+		return append([]byte(nil), d.uid...), nil
+	}
+	if err := d.checkContext(ctx); err != nil {
+		return nil, err
+	}
+	if d.transport == nil {
+		return nil, ErrClosed
+	}
+	uid, err := transportUID(d.transport)
+	if err != nil {
+		return nil, err
+	}
+	d.uid = uid
+	return append([]byte(nil), uid...), nil
+}
+
+// ATR returns the answer-to-reset of the connected card. It fails when the
+// transport does not know the ATR.
+func (d *Device) ATR() ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.transport == nil {
+		return nil, ErrClosed
+	}
+	atr, err := transportATR(d.transport)
+	if err != nil {
+		return nil, err
+	}
+	if atr == nil {
+		return nil, fmt.Errorf("transport does not provide an atr")
+	}
+	return atr, nil
+}
+
+// SetPIN sets the PIN used to authenticate before each write.
+func (d *Device) SetPIN(pin PIN) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pin = pin
+}
+
+// ChangePIN replaces the tag PIN using CHANGE REFERENCE DATA. On success the
+// device authenticates with newPIN from then on.
+func (d *Device) ChangePIN(ctx context.Context, oldPIN, newPIN PIN) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ctx, cancel := d.operationContext(ctx)
+	defer cancel()
+	if err := d.checkContext(ctx); err != nil {
+		return err
+	}
+	if _, err := d.transmitExpect9000(ctx, CommandChangePIN, buildChangePINAPDU(oldPIN, newPIN)); err != nil {
+		return fmt.Errorf("change pin: %w", err)
+	}
+	d.pin = newPIN
+	return nil
+}
+
 func (d *Device) SetMaxFragment(n int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := checkMaxFragment(n); err != nil {
+		return err
+	}
+	d.maxFragment = n
+	return nil
+}
+
+func checkMaxFragment(n int) error {
 	if n <= 0 || n > 250 {
 		return fmt.Errorf("max fragment must be 1..250")
 	}
-	d.maxFragment = n
 	return nil
 }
 
+// SetPolling polls the refresh status every interval, at most attempts times.
 func (d *Device) SetPolling(interval time.Duration, attempts int) error {
-	if interval <= 0 {
-		return fmt.Errorf("poll interval must be > 0")
-	}
-	if attempts <= 0 {
-		return fmt.Errorf("poll attempts must be > 0")
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	strategy, err := FixedPoll(interval, attempts)
+	if err != nil {
+		return err
 	}
-	d.pollInterval = interval
-	d.maxPollAttempt = attempts
+	d.pollStrategy = strategy
 	return nil
 }
 
-func (d *Device) Close() error {
-	var firstErr error
-	if d.card != nil {
-		if err := d.card.Disconnect(scard.ResetCard); err != nil {
-			firstErr = err
-		}
-		d.card = nil
+// SetPollStrategy replaces the refresh polling strategy.
+func (d *Device) SetPollStrategy(strategy PollStrategy) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if strategy == nil {
+		return fmt.Errorf("poll strategy must not be nil")
+	}
+	d.pollStrategy = strategy
+	return nil
+}
+
+// LastRefreshDuration returns how long the last completed refresh took,
+// measured from start-refresh until the tag reported idle.
+func (d *Device) LastRefreshDuration() time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.lastRefresh
+}
+
+// SetRetryBudget sets how many transport failures a single write may
+// recover from. Each recovery re-authenticates and resumes from the first
+// fragment of the block that failed. Zero disables recovery.
+func (d *Device) SetRetryBudget(n int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if n < 0 {
+		return fmt.Errorf("retry budget must be >= 0")
+	}
+	d.retryBudget = n
+	return nil
+}
+
+// SetAutoReconnect lets recovery wait for a removed card to come back when
+// the transport implements Reconnector. It consumes the retry budget. The
+// write resumes only when the tag reports the same UID; otherwise it starts
+// over from the first block.
+func (d *Device) SetAutoReconnect(enabled bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.autoReconnect = enabled
+}
+
+// SetLogger sets the logger for APDU exchanges and device events.
+// Pass nil to disable logging.
+func (d *Device) SetLogger(logger *slog.Logger) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if logger == nil {
+		logger = slog.New(discardHandler{})
+	}
+	d.logger = logger
+}
+
+// SetProgress registers a callback invoked synchronously after each write
+// step. Pass nil to disable progress reporting.
+func (d *Device) SetProgress(fn func(Progress)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.progress = fn
+}
+
+// RecordTrace records every subsequent APDU exchange to w in the trace
+// format read by NewReplayTransport.
+func (d *Device) RecordTrace(w io.Writer) (*TraceRecorder, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.transport == nil {
+		return nil, ErrClosed
+	}
+	rec, err := NewTraceRecorder(w, d.transport, d.profile)
+	if err != nil {
+		return nil, err
 	}
-	if d.ctx != nil {
-		if err := d.ctx.Release(); err != nil && firstErr == nil {
-			firstErr = err
-		}
-		d.ctx = nil
+	d.transport = rec
+	return rec, nil
+}
+
+// Close closes the transport. It waits for a running operation or an open
+// Session to finish. Later operations fail with ErrClosed.
+func (d *Device) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.transport == nil {
+		return nil
 	}
-	return firstErr
+	err := d.transport.Close()
+	d.transport = nil
+	return err
 }
 
 func (d *Device) WriteImage(ctx context.Context, img image.Image) error {
@@ -178,86 +419,233 @@ func (d *Device) WriteImage(ctx context.Context, img image.Image) error {
 }
 
 func (d *Device) WriteImageWithOptions(ctx context.Context, img image.Image, opts ImageEncodeOptions) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	apdus, err := EncodeImageToAPDUsWithOptions(d.profile, img, d.maxFragment, opts)
 	if err != nil {
 		return err
 	}
-	return d.writeAPDUs(ctx, apdus)
+	return d.writeAPDUs(ctx, apdus, true)
 }
 
 func (d *Device) WritePixels(ctx context.Context, pixels []uint8) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	apdus, err := EncodePixelsToAPDUs(d.profile, pixels, d.maxFragment)
 	if err != nil {
 		return err
 	}
-	return d.writeAPDUs(ctx, apdus)
+	return d.writeAPDUs(ctx, apdus, true)
 }
 
-func (d *Device) writeAPDUs(ctx context.Context, imageDataAPDUs [][]byte) error {
-	if err := d.bootstrap(ctx); err != nil {
-		return err
+// writeAPDUs sends image data, starts the refresh and waits for it.
+// authenticate is false inside a Session that already authenticated.
+func (d *Device) writeAPDUs(ctx context.Context, imageDataAPDUs [][]byte, authenticate bool) error {
+	return d.writeFrame(ctx, imageDataAPDUs, authenticate, false)
+}
+
+// writeFrame is writeAPDUs for a full or partial frame. A partial frame only
+// holds the changed blocks, so it cannot be restarted on a different tag;
+// a tag change during the write fails with ErrTagChanged before the refresh.
+func (d *Device) writeFrame(ctx context.Context, imageDataAPDUs [][]byte, authenticate, partial bool) error {
+	ctx, cancel := d.operationContext(ctx)
+	defer cancel()
+	progress := newProgressReporter(d.progress)
+	if authenticate {
+		if err := d.bootstrap(ctx); err != nil {
+			return err
+		}
+		progress.report(PhaseAuthenticate, 0, 0)
+	}
+
+	if d.autoReconnect && d.retryBudget > 0 && canReconnect(d.transport) {
+		// Remember the tag so that recovery can tell whether it came back.
+		d.uidLocked(ctx)
 	}
-	for i, apdu := range imageDataAPDUs {
+
+	retries := d.retryBudget
+	blockStart := 0
+	for i := 0; i < len(imageDataAPDUs); i++ {
+		apdu := imageDataAPDUs[i]
 		if err := d.checkContext(ctx); err != nil {
 			return err
 		}
-		if _, err := d.transmitExpect9000(apdu); err != nil {
-			return fmt.Errorf("send image apdu %d/%d: %w", i+1, len(imageDataAPDUs), err)
+		if len(apdu) > 6 && apdu[6] == 0 {
+			blockStart = i
 		}
+		if _, err := d.transmitExpect9000(ctx, CommandImageData, apdu); err != nil {
+			err = fmt.Errorf("send image apdu %d/%d: %w", i+1, len(imageDataAPDUs), err)
+			if retries == 0 || !d.recoverable(err) {
+				return err
+			}
+			retries--
+			restart, err := d.recover(ctx, err)
+			if err != nil {
+				return err
+			}
+			progress.report(PhaseAuthenticate, 0, 0)
+			if restart && partial {
+				return fmt.Errorf("send image apdu %d/%d: %w", i+1, len(imageDataAPDUs), ErrTagChanged)
+			}
+			if restart {
+				i = -1
+				continue
+			}
+			// Fragments of a block must arrive in order, so resend it from fragment 0.
+			i = blockStart - 1
+			continue
+		}
+		progress.report(PhaseSendAPDU, i+1, len(imageDataAPDUs))
 	}
-	if _, err := d.transmitExpect9000(apduStartRefresh); err != nil {
+	if _, err := d.transmitExpect9000(ctx, CommandStartRefresh, apduStartRefresh); err != nil {
 		return fmt.Errorf("start refresh: %w", err)
 	}
-	return d.pollRefreshDone(ctx)
+	progress.report(PhaseRefreshStarted, 0, 0)
+	if err := d.pollRefreshDone(ctx, progress); err != nil {
+		return err
+	}
+	progress.report(PhaseDone, 0, 0)
+	return nil
 }
 
 func (d *Device) bootstrap(ctx context.Context) error {
 	if err := d.checkContext(ctx); err != nil {
 		return err
 	}
-	if _, err := d.transmitExpect9000(apduAuthenticate); err != nil {
+	if _, err := d.transmitExpect9000(ctx, CommandAuthenticate, buildVerifyAPDU(d.pin)); err != nil {
 		return fmt.Errorf("authenticate: %w", err)
 	}
 	return nil
 }
 
-func (d *Device) pollRefreshDone(ctx context.Context) error {
-	for i := 0; i < d.maxPollAttempt; i++ {
+func (d *Device) recoverable(err error) bool {
+	if d.transport == nil {
+		return false
+	}
+	var se *StatusError
+	if errors.As(err, &se) {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrClosed) {
+		return false
+	}
+	var te *TimeoutError
+	if errors.As(err, &te) && te.Operation {
+		return false
+	}
+	if errors.Is(err, ErrCardRemoved) {
+		return d.autoReconnect && canReconnect(d.transport)
+	}
+	return true
+}
+
+// recover re-authenticates after a transport failure, reconnecting first
+// when the card was removed. restart reports that the tag after reconnect
+// is not known to be the same one, so the write must start from block 0.
+func (d *Device) recover(ctx context.Context, cause error) (restart bool, err error) {
+	d.logger.LogAttrs(ctx, slog.LevelWarn, "recovering from transport error",
+		slog.String("reader", d.reader),
+		slog.Any("error", cause))
+	if errors.Is(cause, ErrCardRemoved) {
+		before := d.uid
+		if err := d.transport.(Reconnector).Reconnect(ctx); err != nil {
+			return false, fmt.Errorf("reconnect after %v: %w", cause, err)
+		}
+		// A different tag may have been placed on the reader. It holds none
+		// of the blocks sent so far.
+		d.uid = nil
+		after, err := d.uidLocked(ctx)
+		if err != nil || before == nil || !bytes.Equal(before, after) {
+			d.logger.LogAttrs(ctx, slog.LevelWarn, "tag changed during write, restarting",
+				slog.String("reader", d.reader),
+				slog.String("uid_before", fmt.Sprintf("%X", before)),
+				slog.String("uid_after", fmt.Sprintf("%X", after)))
+			restart = true
+		}
+	}
+	return restart, d.bootstrap(ctx)
+}
+
+func (d *Device) pollRefreshDone(ctx context.Context, progress progressReporter) error {
+	total := 0
+	if l, ok := d.pollStrategy.(attemptLimiter); ok {
+		total = l.maxAttempts()
+	}
+	start := time.Now()
+	for polls := 1; ; polls++ {
 		if err := d.checkContext(ctx); err != nil {
 			return err
 		}
-		data, err := d.transmitExpect9000(apduPollStatus)
+		data, err := d.transmitExpect9000(ctx, CommandPollStatus, apduPollStatus)
 		if err != nil {
-			return fmt.Errorf("poll status #%d: %w", i+1, err)
+			return fmt.Errorf("poll status #%d: %w", polls, err)
 		}
+		progress.report(PhasePoll, polls, total)
 		if len(data) > 0 {
 			switch data[0] {
 			case 0x00:
+				d.lastRefresh = time.Since(start)
 				return nil
 			case 0x01:
 				// still refreshing
 			default:
-				return fmt.Errorf("unexpected refresh status 0x%02X", data[0])
+				return fmt.Errorf("%w 0x%02X", ErrUnexpectedRefreshStatus, data[0])
 			}
 		}
-		time.Sleep(d.pollInterval)
+		delay, ok := d.pollStrategy.NextDelay(polls, time.Since(start))
+		if !ok {
+			return fmt.Errorf("%w after %d polls (%s)", ErrRefreshTimeout, polls, time.Since(start).Round(time.Millisecond))
+		}
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
 	}
-	return fmt.Errorf("refresh timeout")
 }
 
-func (d *Device) transmitExpect9000(apdu []byte) ([]byte, error) {
-	data, sw1, sw2, err := d.transmit(apdu)
+func (d *Device) transmitExpect9000(ctx context.Context, command string, apdu []byte) ([]byte, error) {
+	data, sw1, sw2, err := d.transmit(ctx, command, apdu)
 	if err != nil {
 		return nil, err
 	}
 	if sw1 != 0x90 || sw2 != 0x00 {
-		return nil, fmt.Errorf("status %02X%02X", sw1, sw2)
+		return nil, &StatusError{SW1: sw1, SW2: sw2, Command: command}
 	}
 	return data, nil
 }
 
-func (d *Device) transmit(apdu []byte) ([]byte, byte, byte, error) {
-	resp, err := d.card.Transmit(apdu)
+// Transmit sends a raw command APDU and returns the response data and status
+// word. Non-9000 status words are not treated as errors, and no
+// authentication is performed; send VERIFY yourself if the command needs it.
+func (d *Device) Transmit(ctx context.Context, apdu []byte) ([]byte, uint16, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.transmitRaw(ctx, apdu)
+}
+
+func (d *Device) transmitRaw(ctx context.Context, apdu []byte) ([]byte, uint16, error) {
+	ctx, cancel := d.operationContext(ctx)
+	defer cancel()
+	if err := d.checkContext(ctx); err != nil {
+		return nil, 0, err
+	}
+	if len(apdu) < 4 {
+		return nil, 0, fmt.Errorf("apdu too short: %d bytes", len(apdu))
+	}
+	data, sw1, sw2, err := d.transmit(ctx, CommandRaw, apdu)
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, uint16(sw1)<<8 | uint16(sw2), nil
+}
+
+func (d *Device) transmit(ctx context.Context, command string, apdu []byte) ([]byte, byte, byte, error) {
+	if d.transport == nil {
+		return nil, 0, 0, ErrClosed
+	}
+	d.logCommand(ctx, command, apdu)
+	start := time.Now()
+	resp, err := d.transmitWatched(ctx, command, apdu)
+	d.logResponse(ctx, command, resp, time.Since(start), err)
 	if err != nil {
 		return nil, 0, 0, err
 	}
@@ -272,7 +660,7 @@ func (d *Device) transmit(apdu []byte) ([]byte, byte, byte, error) {
 func (d *Device) checkContext(ctx context.Context) error {
 	select {
 	case <-ctx.Done():
-		return ctx.Err()
+		return context.Cause(ctx)
 	default:
 		return nil
 	}
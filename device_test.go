@@ -1,14 +1,53 @@
 package ezsignnfc
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
 
-func TestResolveReader(t *testing.T) {
-	readers := []string{"Reader A", "Reader B"}
+type funcTransport func(apdu []byte) ([]byte, error)
+
+func (f funcTransport) Transmit(apdu []byte) ([]byte, error) { return f(apdu) }
+func (f funcTransport) Close() error                         { return nil }
+func (f funcTransport) ReaderName() string                   { return "Func Reader" }
+
+type fakeTransport struct {
+	sent   [][]byte
+	polls  int
+	closed bool
+}
+
+func (f *fakeTransport) Transmit(apdu []byte) ([]byte, error) {
+	f.sent = append(f.sent, append([]byte(nil), apdu...))
+	if bytes.Equal(apdu, apduPollStatus) {
+		f.polls++
+		if f.polls < 3 {
+			return []byte{0x01, 0x90, 0x00}, nil
+		}
+		return []byte{0x00, 0x90, 0x00}, nil
+	}
+	return []byte{0x90, 0x00}, nil
+}
+
+func (f *fakeTransport) Close() error {
+	f.closed = true
+	return nil
+}
+
+func (f *fakeTransport) ReaderName() string {
+	return "Fake Reader"
+}
+
+func TestResolveReaderState(t *testing.T) {
+	readers := []readerState{{Name: "Reader A"}, {Name: "Reader B"}}
 
 	t.Run("default-first-reader", func(t *testing.T) {
-		got, err := resolveReader(readers, nil)
+		got, err := resolveReaderState(readers, nil)
 		if err != nil {
-			t.Fatalf("resolveReader default: %v", err)
+			t.Fatalf("resolveReaderState default: %v", err)
 		}
 		if got != "Reader A" {
 			t.Fatalf("default reader: got %q want %q", got, "Reader A")
@@ -16,9 +55,9 @@ func TestResolveReader(t *testing.T) {
 	})
 
 	t.Run("reader-index", func(t *testing.T) {
-		got, err := resolveReader(readers, []ReaderSelector{ReaderIndex(1)})
+		got, err := resolveReaderState(readers, []ReaderSelector{ReaderIndex(1)})
 		if err != nil {
-			t.Fatalf("resolveReader index: %v", err)
+			t.Fatalf("resolveReaderState index: %v", err)
 		}
 		if got != "Reader B" {
 			t.Fatalf("reader index: got %q want %q", got, "Reader B")
@@ -26,9 +65,9 @@ func TestResolveReader(t *testing.T) {
 	})
 
 	t.Run("reader-name", func(t *testing.T) {
-		got, err := resolveReader(readers, []ReaderSelector{ReaderName("Reader B")})
+		got, err := resolveReaderState(readers, []ReaderSelector{ReaderName("Reader B")})
 		if err != nil {
-			t.Fatalf("resolveReader name: %v", err)
+			t.Fatalf("resolveReaderState name: %v", err)
 		}
 		if got != "Reader B" {
 			t.Fatalf("reader name: got %q want %q", got, "Reader B")
@@ -36,28 +75,425 @@ func TestResolveReader(t *testing.T) {
 	})
 
 	t.Run("bad-index", func(t *testing.T) {
-		if _, err := resolveReader(readers, []ReaderSelector{ReaderIndex(2)}); err == nil {
+		if _, err := resolveReaderState(readers, []ReaderSelector{ReaderIndex(2)}); err == nil {
 			t.Fatal("expected error for out-of-range index")
 		}
 	})
 
 	t.Run("bad-name", func(t *testing.T) {
-		if _, err := resolveReader(readers, []ReaderSelector{ReaderName("Missing")}); err == nil {
+		if _, err := resolveReaderState(readers, []ReaderSelector{ReaderName("Missing")}); err == nil {
 			t.Fatal("expected error for missing reader name")
 		}
 	})
 
 	t.Run("too-many-selectors", func(t *testing.T) {
-		_, err := resolveReader(readers, []ReaderSelector{ReaderIndex(0), ReaderName("Reader A")})
+		_, err := resolveReaderState(readers, []ReaderSelector{ReaderIndex(0), ReaderName("Reader A")})
 		if err == nil {
 			t.Fatal("expected error for multiple selectors")
 		}
 	})
 
 	t.Run("nil-selector", func(t *testing.T) {
-		_, err := resolveReader(readers, []ReaderSelector{nil})
+		_, err := resolveReaderState(readers, []ReaderSelector{nil})
 		if err == nil {
 			t.Fatal("expected error for nil selector")
 		}
 	})
 }
+
+func TestOpenTransportWritePixels(t *testing.T) {
+	profile := PresetProfiles[Product29Mono]
+	tr := &fakeTransport{}
+	dev, err := OpenTransport(profile, tr)
+	if err != nil {
+		t.Fatalf("OpenTransport: %v", err)
+	}
+	if err := dev.SetPolling(time.Millisecond, 10); err != nil {
+		t.Fatal(err)
+	}
+	if got := dev.ReaderName(); got != "Fake Reader" {
+		t.Fatalf("reader name: got %q", got)
+	}
+
+	pixels := make([]uint8, profile.Width*profile.Height)
+	if err := dev.WritePixels(context.Background(), pixels); err != nil {
+		t.Fatalf("WritePixels: %v", err)
+	}
+
+	want, err := EncodePixelsToAPDUs(profile, pixels, 250)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.sent) != 1+len(want)+1+3 {
+		t.Fatalf("sent apdu count: got %d want %d", len(tr.sent), 1+len(want)+1+3)
+	}
+	if !bytes.Equal(tr.sent[0], buildVerifyAPDU(DefaultPIN)) {
+		t.Fatalf("first apdu: got %X want authenticate", tr.sent[0])
+	}
+	for i, apdu := range want {
+		if !bytes.Equal(tr.sent[1+i], apdu) {
+			t.Fatalf("image apdu %d mismatch", i)
+		}
+	}
+	if !bytes.Equal(tr.sent[1+len(want)], apduStartRefresh) {
+		t.Fatalf("expected start refresh after image data, got %X", tr.sent[1+len(want)])
+	}
+
+	if err := dev.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if !tr.closed {
+		t.Fatal("expected transport to be closed")
+	}
+}
+
+func TestOpenTransportNil(t *testing.T) {
+	if _, err := OpenTransport(PresetProfiles[Product29Mono], nil); err == nil {
+		t.Fatal("expected error for nil transport")
+	}
+}
+
+func TestDeviceTypedErrors(t *testing.T) {
+	profile := PresetProfiles[Product29Mono]
+	pixels := make([]uint8, profile.Width*profile.Height)
+
+	t.Run("auth-failed", func(t *testing.T) {
+		dev, _ := OpenTransport(profile, funcTransport(func(apdu []byte) ([]byte, error) {
+			return []byte{0x63, 0xC2}, nil
+		}))
+		err := dev.WritePixels(context.Background(), pixels)
+		if !errors.Is(err, ErrAuthFailed) {
+			t.Fatalf("expected ErrAuthFailed, got %v", err)
+		}
+		var se *StatusError
+		if !errors.As(err, &se) {
+			t.Fatalf("expected StatusError, got %T", err)
+		}
+		if se.SW() != 0x63C2 || se.Command != CommandAuthenticate {
+			t.Fatalf("status error: got %04X/%s", se.SW(), se.Command)
+		}
+	})
+
+	t.Run("image-data-status", func(t *testing.T) {
+		dev, _ := OpenTransport(profile, funcTransport(func(apdu []byte) ([]byte, error) {
+			if apdu[1] == 0xD3 {
+				return []byte{0x6A, 0x80}, nil
+			}
+			return []byte{0x90, 0x00}, nil
+		}))
+		err := dev.WritePixels(context.Background(), pixels)
+		var se *StatusError
+		if !errors.As(err, &se) || se.Command != CommandImageData {
+			t.Fatalf("expected image-data StatusError, got %v", err)
+		}
+		if errors.Is(err, ErrAuthFailed) {
+			t.Fatal("image-data status must not match ErrAuthFailed")
+		}
+	})
+
+	t.Run("card-removed", func(t *testing.T) {
+		dev, _ := OpenTransport(profile, funcTransport(func(apdu []byte) ([]byte, error) {
+			return nil, ErrCardRemoved
+		}))
+		if err := dev.WritePixels(context.Background(), pixels); !errors.Is(err, ErrCardRemoved) {
+			t.Fatalf("expected ErrCardRemoved, got %v", err)
+		}
+	})
+
+	t.Run("refresh-timeout", func(t *testing.T) {
+		sim := NewSimulator(profile)
+		sim.SetRefreshLatency(time.Hour)
+		dev, _ := OpenTransport(profile, sim)
+		dev.SetPolling(time.Millisecond, 3)
+		if err := dev.WritePixels(context.Background(), pixels); !errors.Is(err, ErrRefreshTimeout) {
+			t.Fatalf("expected ErrRefreshTimeout, got %v", err)
+		}
+	})
+
+	t.Run("unexpected-refresh-status", func(t *testing.T) {
+		dev, _ := OpenTransport(profile, funcTransport(func(apdu []byte) ([]byte, error) {
+			if bytes.Equal(apdu, apduPollStatus) {
+				return []byte{0x05, 0x90, 0x00}, nil
+			}
+			return []byte{0x90, 0x00}, nil
+		}))
+		if err := dev.WritePixels(context.Background(), pixels); !errors.Is(err, ErrUnexpectedRefreshStatus) {
+			t.Fatalf("expected ErrUnexpectedRefreshStatus, got %v", err)
+		}
+	})
+}
+
+// flakyTransport wraps a Simulator and drops the card once at a given APDU.
+type flakyTransport struct {
+	*Simulator
+	failAt     int
+	failErr    error
+	count      int
+	reconnects int
+	// swapTo replaces the tag on reconnect.
+	swapTo *Simulator
+}
+
+func (f *flakyTransport) Transmit(apdu []byte) ([]byte, error) {
+	f.count++
+	if f.count == f.failAt {
+		return nil, f.failErr
+	}
+	return f.Simulator.Transmit(apdu)
+}
+
+func (f *flakyTransport) Reconnect(ctx context.Context) error {
+	f.reconnects++
+	if f.swapTo != nil {
+		f.Simulator = f.swapTo
+	}
+	return nil
+}
+
+func TestDeviceResumeAfterCardRemoval(t *testing.T) {
+	profile := PresetProfiles[Product29Quad]
+	pixels := make([]uint8, profile.Width*profile.Height)
+	for i := range pixels {
+		pixels[i] = uint8(i*31%17) % 4
+	}
+	apdus, err := EncodePixelsToAPDUs(profile, pixels, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Fail on the second fragment of some block: the first APDU is authenticate.
+	failAt := -1
+	for i, apdu := range apdus {
+		if apdu[5] > 0 && apdu[6] == 1 {
+			failAt = i + 2
+			break
+		}
+	}
+	if failAt < 0 {
+		t.Fatal("expected a multi-fragment block after block 0")
+	}
+
+	t.Run("reconnect", func(t *testing.T) {
+		// The UID is read before authenticate when auto reconnect is enabled.
+		tr := &flakyTransport{Simulator: NewSimulator(profile), failAt: failAt + 1, failErr: ErrCardRemoved}
+		dev, _ := OpenTransport(profile, tr)
+		dev.SetRetryBudget(1)
+		dev.SetAutoReconnect(true)
+		if err := dev.WritePixels(context.Background(), pixels); err != nil {
+			t.Fatalf("WritePixels: %v", err)
+		}
+		if tr.reconnects != 1 {
+			t.Fatalf("reconnects: got %d want 1", tr.reconnects)
+		}
+		got := tr.Pixels()
+		for i := range pixels {
+			if got[i] != pixels[i] {
+				t.Fatalf("pixel %d: got %d want %d", i, got[i], pixels[i])
+			}
+		}
+	})
+
+	t.Run("reconnect-different-tag", func(t *testing.T) {
+		first := NewSimulator(profile)
+		second := NewSimulator(profile)
+		second.SetIdentity([]byte{0x04, 0x99, 0x88, 0x77}, nil)
+		tr := &flakyTransport{Simulator: first, failAt: failAt + 1, failErr: ErrCardRemoved, swapTo: second}
+		dev, _ := OpenTransport(profile, tr)
+		dev.SetRetryBudget(1)
+		dev.SetAutoReconnect(true)
+		if err := dev.WritePixels(context.Background(), pixels); err != nil {
+			t.Fatalf("WritePixels: %v", err)
+		}
+		if first.Refreshes() != 0 || second.Refreshes() != 1 {
+			t.Fatalf("refreshes: first %d second %d", first.Refreshes(), second.Refreshes())
+		}
+		got := second.Pixels()
+		for i := range pixels {
+			if got[i] != pixels[i] {
+				t.Fatalf("pixel %d on new tag: got %d want %d", i, got[i], pixels[i])
+			}
+		}
+		uid, _ := dev.UID(context.Background())
+		if !bytes.Equal(uid, []byte{0x04, 0x99, 0x88, 0x77}) {
+			t.Fatalf("uid after swap: %X", uid)
+		}
+	})
+
+	t.Run("reconnect-disabled", func(t *testing.T) {
+		tr := &flakyTransport{Simulator: NewSimulator(profile), failAt: failAt, failErr: ErrCardRemoved}
+		dev, _ := OpenTransport(profile, tr)
+		dev.SetRetryBudget(1)
+		if err := dev.WritePixels(context.Background(), pixels); !errors.Is(err, ErrCardRemoved) {
+			t.Fatalf("expected ErrCardRemoved, got %v", err)
+		}
+	})
+
+	t.Run("transient-retry", func(t *testing.T) {
+		tr := &flakyTransport{Simulator: NewSimulator(profile), failAt: failAt, failErr: errors.New("transient")}
+		dev, _ := OpenTransport(profile, tr)
+		if err := dev.WritePixels(context.Background(), pixels); err == nil {
+			t.Fatal("expected failure without retry budget")
+		}
+
+		tr = &flakyTransport{Simulator: NewSimulator(profile), failAt: failAt, failErr: errors.New("transient")}
+		dev, _ = OpenTransport(profile, tr)
+		dev.SetRetryBudget(1)
+		if err := dev.WritePixels(context.Background(), pixels); err != nil {
+			t.Fatalf("WritePixels with retry: %v", err)
+		}
+		if tr.reconnects != 0 {
+			t.Fatalf("transient errors must not reconnect, got %d", tr.reconnects)
+		}
+	})
+}
+
+func TestDeviceProgress(t *testing.T) {
+	profile := PresetProfiles[Product29Mono]
+	sim := NewSimulator(profile)
+	sim.SetRefreshLatency(10 * time.Millisecond)
+	dev, _ := OpenTransport(profile, sim)
+	dev.SetPolling(2*time.Millisecond, 100)
+
+	var events []Progress
+	dev.SetProgress(func(p Progress) { events = append(events, p) })
+	pixels := make([]uint8, profile.Width*profile.Height)
+	if err := dev.WritePixels(context.Background(), pixels); err != nil {
+		t.Fatal(err)
+	}
+	apdus, _ := EncodePixelsToAPDUs(profile, pixels, 250)
+
+	if len(events) < 1+len(apdus)+3 {
+		t.Fatalf("too few progress events: %d", len(events))
+	}
+	if events[0].Phase != PhaseAuthenticate {
+		t.Fatalf("first phase: got %v", events[0].Phase)
+	}
+	for i := range apdus {
+		ev := events[1+i]
+		if ev.Phase != PhaseSendAPDU || ev.Index != i+1 || ev.Total != len(apdus) {
+			t.Fatalf("send event %d: got %+v", i, ev)
+		}
+	}
+	if ev := events[1+len(apdus)]; ev.Phase != PhaseRefreshStarted {
+		t.Fatalf("expected refresh-started, got %v", ev.Phase)
+	}
+	polls := events[2+len(apdus) : len(events)-1]
+	if len(polls) < 2 {
+		t.Fatalf("expected multiple polls during refresh latency, got %d", len(polls))
+	}
+	for _, ev := range polls {
+		if ev.Phase != PhasePoll {
+			t.Fatalf("expected poll phase, got %v", ev.Phase)
+		}
+	}
+	last := events[len(events)-1]
+	if last.Phase != PhaseDone || last.Elapsed < 10*time.Millisecond {
+		t.Fatalf("last event: got %+v", last)
+	}
+}
+
+func TestDevicePIN(t *testing.T) {
+	profile := PresetProfiles[Product29Mono]
+	pixels := make([]uint8, profile.Width*profile.Height)
+	sim := NewSimulator(profile)
+	dev, _ := OpenTransport(profile, sim)
+
+	newPIN, err := ParsePIN("01020304")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dev.ChangePIN(context.Background(), DefaultPIN, newPIN); err != nil {
+		t.Fatalf("ChangePIN: %v", err)
+	}
+	if err := dev.WritePixels(context.Background(), pixels); err != nil {
+		t.Fatalf("write with changed pin: %v", err)
+	}
+
+	dev.SetPIN(DefaultPIN)
+	err = dev.WritePixels(context.Background(), pixels)
+	if !errors.Is(err, ErrWrongPIN) || !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("expected ErrWrongPIN, got %v", err)
+	}
+	var se *StatusError
+	if !errors.As(err, &se) {
+		t.Fatalf("expected StatusError, got %T", err)
+	}
+	if retries, ok := se.RetriesLeft(); !ok || retries != 2 {
+		t.Fatalf("retries left: got %d/%v want 2", retries, ok)
+	}
+
+	if err := dev.ChangePIN(context.Background(), DefaultPIN, newPIN); !errors.Is(err, ErrWrongPIN) {
+		t.Fatalf("change pin with wrong old pin: got %v", err)
+	}
+	err = dev.WritePixels(context.Background(), pixels)
+	if !errors.Is(err, ErrPINBlocked) {
+		t.Fatalf("expected ErrPINBlocked after last try, got %v", err)
+	}
+	dev.SetPIN(newPIN)
+	if err := dev.WritePixels(context.Background(), pixels); !errors.Is(err, ErrPINBlocked) {
+		t.Fatalf("expected blocked tag to stay blocked, got %v", err)
+	}
+
+	if _, err := ParsePIN("0102"); err == nil {
+		t.Fatal("expected error for short pin")
+	}
+}
+
+func TestDeviceIdentity(t *testing.T) {
+	profile := PresetProfiles[Product29Mono]
+	sim := NewSimulator(profile)
+	sim.SetIdentity([]byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}, []byte{0x3B, 0x8F, 0x80, 0x01})
+	dev, _ := OpenTransport(profile, sim)
+
+	uid, err := dev.UID(context.Background())
+	if err != nil {
+		t.Fatalf("UID: %v", err)
+	}
+	if !bytes.Equal(uid, []byte{0x04, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}) {
+		t.Fatalf("uid: got %X", uid)
+	}
+	atr, err := dev.ATR()
+	if err != nil {
+		t.Fatalf("ATR: %v", err)
+	}
+	if !bytes.Equal(atr, []byte{0x3B, 0x8F, 0x80, 0x01}) {
+		t.Fatalf("atr: got %X", atr)
+	}
+
+	plain, _ := OpenTransport(profile, &fakeTransport{})
+	if _, err := plain.ATR(); err == nil {
+		t.Fatal("expected error for transport without atr")
+	}
+}
+
+func TestDeviceTransmitRaw(t *testing.T) {
+	profile := PresetProfiles[Product29Mono]
+	sim := NewSimulator(profile)
+	sim.SetIdentity([]byte{0x04, 0x11, 0x22, 0x33}, nil)
+	dev, _ := OpenTransport(profile, sim)
+	ctx := context.Background()
+
+	data, sw, err := dev.Transmit(ctx, apduGetUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sw != 0x9000 || !bytes.Equal(data, []byte{0x04, 0x11, 0x22, 0x33}) {
+		t.Fatalf("get uid: got %X %04X", data, sw)
+	}
+
+	// Status words other than 9000 are returned, not turned into errors.
+	_, sw, err = dev.Transmit(ctx, []byte{0xF0, 0xE0, 0x00, 0x00, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sw != 0x6D00 {
+		t.Fatalf("unknown instruction: got %04X want 6D00", sw)
+	}
+
+	if _, _, err := dev.Transmit(ctx, []byte{0x00, 0x20}); err == nil {
+		t.Fatal("expected error for short apdu")
+	}
+	dev.Close()
+	if _, _, err := dev.Transmit(ctx, apduGetUID); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package ezsignnfc
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// FrameCache remembers the last frame written to each tag, keyed by UID,
+// for WritePixelsDiff. It is safe for concurrent use.
+//
+// Only WritePixelsDiff updates the cache. After writing a tag any other way
+// (WritePixels, WriteImage, WriteEncoded, a Session or a BatchWriter), call
+// Forget for its UID; otherwise the next diff is computed against a stale
+// frame and the display ends up mixing both images.
+type FrameCache struct {
+	mu     sync.Mutex
+	frames map[string]cachedFrame
+}
+
+type cachedFrame struct {
+	product     Product
+	pixels      []uint8
+	fullOnly    bool
+	hasContents bool
+}
+
+// NewFrameCache returns an empty frame cache.
+func NewFrameCache() *FrameCache {
+	return &FrameCache{frames: make(map[string]cachedFrame)}
+}
+
+// Forget drops everything known about the tag with uid.
+func (c *FrameCache) Forget(uid []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.frames, hex.EncodeToString(uid))
+}
+
+func (c *FrameCache) get(key string) cachedFrame {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.frames[key]
+}
+
+func (c *FrameCache) put(key string, f cachedFrame) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.frames[key] = f
+}
+
+// WritePixelsDiff writes only the 20-row blocks that changed since the last
+// frame cached for this tag. Partial updates rely on the tag keeping the
+// blocks it does not receive. When the tag refuses to refresh a partial
+// frame with 6985, the tag is remembered as full-frame only and a full write
+// is done instead. Without a cached frame, or when the UID cannot be read,
+// it does a full write; an unchanged frame is not written at all. If auto
+// reconnect brings back a different tag, the frame is written in full to
+// that tag and cached under its UID. The cache must only see writes made
+// through WritePixelsDiff; see FrameCache.
+func (d *Device) WritePixelsDiff(ctx context.Context, pixels []uint8, cache *FrameCache) error {
+	if cache == nil {
+		return fmt.Errorf("frame cache must not be nil")
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := validatePixels(d.profile, pixels); err != nil {
+		return err
+	}
+	uid, err := d.uidLocked(ctx)
+	if err != nil {
+		// Without a UID there is no cache key, so nothing can be skipped.
+		d.logger.LogAttrs(ctx, slog.LevelWarn, "uid unavailable, writing full frame",
+			slog.String("reader", d.reader),
+			slog.Any("error", err))
+		return d.writePixelsFull(ctx, pixels)
+	}
+	key := hex.EncodeToString(uid)
+	prev := cache.get(key)
+	next := cachedFrame{product: d.profile.Product, pixels: append([]uint8(nil), pixels...), fullOnly: prev.fullOnly, hasContents: true}
+
+	if prev.hasContents && prev.product == d.profile.Product {
+		if bytes.Equal(prev.pixels, pixels) {
+			return nil
+		}
+		if !prev.fullOnly {
+			err := d.writePixelsPartial(ctx, prev.pixels, pixels)
+			switch {
+			case err == nil:
+				cache.put(key, next)
+				return nil
+			case errors.Is(err, ErrTagChanged):
+				// The new tag has none of the cached blocks.
+				d.logger.LogAttrs(ctx, slog.LevelWarn, "tag changed during diff write, writing full frame",
+					slog.String("reader", d.reader))
+				next.fullOnly = false
+			case fullFrameRequired(err):
+				next.fullOnly = true
+			default:
+				cache.Forget(uid)
+				return err
+			}
+		}
+	}
+
+	err = d.writePixelsFull(ctx, pixels)
+	if !bytes.Equal(d.uid, uid) {
+		// Recovery reconnected to another tag. The old one left mid-write,
+		// so its contents are unknown.
+		cache.Forget(uid)
+		if d.uid == nil {
+			return err
+		}
+		uid, key = d.uid, hex.EncodeToString(d.uid)
+		next.fullOnly = cache.get(key).fullOnly
+	}
+	if err != nil {
+		cache.Forget(uid)
+		return err
+	}
+	cache.put(key, next)
+	return nil
+}
+
+func (d *Device) writePixelsFull(ctx context.Context, pixels []uint8) error {
+	apdus, err := EncodePixelsToAPDUs(d.profile, pixels, d.maxFragment)
+	if err != nil {
+		return err
+	}
+	return d.writeAPDUs(ctx, apdus, true)
+}
+
+func (d *Device) writePixelsPartial(ctx context.Context, prev, next []uint8) error {
+	apdus, err := EncodePixelsDiffToAPDUs(d.profile, prev, next, d.maxFragment)
+	if err != nil {
+		return err
+	}
+	return d.writeFrame(ctx, apdus, true, true)
+}
+
+// fullFrameRequired reports whether err is the tag refusing to refresh
+// because blocks are missing, as opposed to a bad fragment, authentication
+// or transport failure. That tags answer 6985 to start refresh in this case
+// is an assumption matching the Simulator; it has not been observed on
+// hardware.
+func fullFrameRequired(err error) bool {
+	var se *StatusError
+	if !errors.As(err, &se) {
+		return false
+	}
+	return se.Command == CommandStartRefresh && se.SW() == 0x6985
+}
@@ -0,0 +1,187 @@
+package ezsignnfc
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestWritePixelsDiff(t *testing.T) {
+	profile := PresetProfiles[Product42Mono]
+	base := make([]uint8, profile.Width*profile.Height)
+	for i := range base {
+		base[i] = uint8(i%3) & 1
+	}
+	clock := append([]uint8(nil), base...)
+	for x := 0; x < 50; x++ {
+		clock[45*profile.Width+x] ^= 1
+	}
+
+	for _, fullOnly := range []bool{false, true} {
+		sim := NewSimulator(profile)
+		sim.SetRequireFullFrame(fullOnly)
+		dev, _ := OpenTransport(profile, sim)
+		var sent int
+		dev.SetProgress(func(p Progress) {
+			if p.Phase == PhaseSendAPDU {
+				sent++
+			}
+		})
+		cache := NewFrameCache()
+
+		write := func(pixels []uint8) {
+			t.Helper()
+			sent = 0
+			if err := dev.WritePixelsDiff(context.Background(), pixels, cache); err != nil {
+				t.Fatalf("fullOnly=%v: WritePixelsDiff: %v", fullOnly, err)
+			}
+			got := sim.Pixels()
+			for i := range pixels {
+				if got[i] != pixels[i] {
+					t.Fatalf("fullOnly=%v: pixel %d: got %d want %d", fullOnly, i, got[i], pixels[i])
+				}
+			}
+		}
+
+		full, _ := EncodePixelsToAPDUs(profile, base, 250)
+		write(base)
+		if sent != len(full) {
+			t.Fatalf("fullOnly=%v: first write sent %d apdus want %d", fullOnly, sent, len(full))
+		}
+
+		partial, _ := EncodePixelsDiffToAPDUs(profile, base, clock, 250)
+		write(clock)
+		if !fullOnly && sent != len(partial) {
+			t.Fatalf("diff write sent %d apdus want %d", sent, len(partial))
+		}
+		if fullOnly && sent != len(partial)+len(full) {
+			t.Fatalf("fallback write sent %d apdus want %d", sent, len(partial)+len(full))
+		}
+
+		write(base)
+		if fullOnly && sent != len(full) {
+			t.Fatalf("full-frame tag must skip diff attempts, sent %d apdus", sent)
+		}
+		refreshes := sim.Refreshes()
+		write(base)
+		if sent != 0 || sim.Refreshes() != refreshes {
+			t.Fatalf("fullOnly=%v: unchanged frame must not be written, sent %d", fullOnly, sent)
+		}
+	}
+}
+
+func TestWritePixelsDiffErrors(t *testing.T) {
+	profile := PresetProfiles[Product29Mono]
+	base := make([]uint8, profile.Width*profile.Height)
+	next := append([]uint8(nil), base...)
+	next[0] ^= 1
+	full, _ := EncodePixelsToAPDUs(profile, base, 250)
+
+	t.Run("no-uid", func(t *testing.T) {
+		sim := NewSimulator(profile)
+		dev, _ := OpenTransport(profile, funcTransport(func(apdu []byte) ([]byte, error) {
+			if bytes.Equal(apdu, apduGetUID) {
+				return []byte{0x6A, 0x81}, nil
+			}
+			return sim.Transmit(apdu)
+		}))
+		var sent int
+		dev.SetProgress(func(p Progress) {
+			if p.Phase == PhaseSendAPDU {
+				sent++
+			}
+		})
+		cache := NewFrameCache()
+		for _, pixels := range [][]uint8{base, base} {
+			sent = 0
+			if err := dev.WritePixelsDiff(context.Background(), pixels, cache); err != nil {
+				t.Fatalf("WritePixelsDiff without uid: %v", err)
+			}
+			if sent != len(full) {
+				t.Fatalf("sent %d apdus want full frame %d", sent, len(full))
+			}
+		}
+	})
+
+	t.Run("tag-changed", func(t *testing.T) {
+		first := NewSimulator(profile)
+		second := NewSimulator(profile)
+		second.SetIdentity([]byte{0x04, 0x99, 0x88, 0x77}, nil)
+		tr := &flakyTransport{Simulator: first, failErr: ErrCardRemoved, swapTo: second}
+		dev, _ := OpenTransport(profile, tr)
+		dev.SetRetryBudget(1)
+		dev.SetAutoReconnect(true)
+		cache := NewFrameCache()
+		if err := dev.WritePixelsDiff(context.Background(), base, cache); err != nil {
+			t.Fatal(err)
+		}
+		oldKey := "045a1c22916b80"
+
+		// VERIFY passes, then the tag is swapped on the first changed block.
+		tr.failAt = tr.count + 2
+		if err := dev.WritePixelsDiff(context.Background(), next, cache); err != nil {
+			t.Fatalf("WritePixelsDiff across a tag swap: %v", err)
+		}
+		if first.Refreshes() != 1 || second.Refreshes() != 1 {
+			t.Fatalf("refreshes: first %d second %d", first.Refreshes(), second.Refreshes())
+		}
+		if !bytes.Equal(second.Pixels(), next) {
+			t.Fatal("new tag must get the full frame")
+		}
+		if cache.get(oldKey).hasContents {
+			t.Fatal("old tag still cached")
+		}
+		if got := cache.get("04998877"); !bytes.Equal(got.pixels, next) {
+			t.Fatal("frame not cached under the new uid")
+		}
+	})
+
+	t.Run("bad-fragment", func(t *testing.T) {
+		sim := NewSimulator(profile)
+		var rejectNext bool
+		dev, _ := OpenTransport(profile, funcTransport(func(apdu []byte) ([]byte, error) {
+			if rejectNext && apdu[1] == 0xD3 {
+				rejectNext = false
+				return []byte{0x6A, 0x80}, nil
+			}
+			return sim.Transmit(apdu)
+		}))
+		cache := NewFrameCache()
+		if err := dev.WritePixelsDiff(context.Background(), base, cache); err != nil {
+			t.Fatal(err)
+		}
+		rejectNext = true
+		var se *StatusError
+		if err := dev.WritePixelsDiff(context.Background(), next, cache); !errors.As(err, &se) || se.SW() != 0x6A80 {
+			t.Fatalf("expected 6A80, got %v", err)
+		}
+		// A bad fragment says nothing about partial support: the tag is not
+		// marked full-frame only.
+		uid, _ := dev.UID(context.Background())
+		if cache.get(hex.EncodeToString(uid)).fullOnly {
+			t.Fatal("tag marked full-frame only after a bad fragment")
+		}
+	})
+}
+
+func TestEncodePixelsDiffToAPDUs(t *testing.T) {
+	profile := PresetProfiles[Product29Quad]
+	prev := make([]uint8, profile.Width*profile.Height)
+	next := append([]uint8(nil), prev...)
+	next[len(next)-1] = ColorRed
+
+	apdus, err := EncodePixelsDiffToAPDUs(profile, prev, next, 250)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, apdu := range apdus {
+		if int(apdu[5]) != profile.BlockCount()-1 {
+			t.Fatalf("unexpected block %d in diff", apdu[5])
+		}
+	}
+	if len(apdus) == 0 {
+		t.Fatal("expected apdus for the last block")
+	}
+}
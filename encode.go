@@ -24,6 +24,40 @@ func EncodeImageToAPDUsWithOptions(profile Profile, img image.Image, maxFragment
 
 // EncodePixelsToAPDUs packs indexed pixels into panel blocks and returns F0D3 APDUs.
 func EncodePixelsToAPDUs(profile Profile, pixels []uint8, maxFragment int) ([][]byte, error) {
+	return encodePixelBlocksToAPDUs(profile, pixels, maxFragment, nil)
+}
+
+// EncodePixelsDiffToAPDUs returns F0D3 APDUs only for the 20-row blocks whose
+// pixels differ between prev and next.
+func EncodePixelsDiffToAPDUs(profile Profile, prev, next []uint8, maxFragment int) ([][]byte, error) {
+	if err := validatePixels(profile, prev); err != nil {
+		return nil, fmt.Errorf("previous frame: %w", err)
+	}
+	return encodePixelBlocksToAPDUs(profile, next, maxFragment, changedBlocks(profile, prev, next))
+}
+
+// changedBlocks marks blocks whose rows differ. Both frames must be full size.
+func changedBlocks(profile Profile, prev, next []uint8) []bool {
+	changed := make([]bool, profile.BlockCount())
+	for b := range changed {
+		start := b * blockRows * profile.Width
+		end := (b + 1) * blockRows * profile.Width
+		if end > len(next) {
+			end = len(next)
+		}
+		for i := start; i < end; i++ {
+			if prev[i] != next[i] {
+				changed[b] = true
+				break
+			}
+		}
+	}
+	return changed
+}
+
+// encodePixelBlocksToAPDUs encodes the blocks selected by include, or all
+// blocks when include is nil.
+func encodePixelBlocksToAPDUs(profile Profile, pixels []uint8, maxFragment int, include []bool) ([][]byte, error) {
 	if maxFragment <= 0 || maxFragment > 250 {
 		return nil, fmt.Errorf("maxFragment must be 1..250: %d", maxFragment)
 	}
@@ -38,7 +72,10 @@ func EncodePixelsToAPDUs(profile Profile, pixels []uint8, maxFragment int) ([][]
 
 	apdus := make([][]byte, 0, len(blocks)*4)
 	for blockNo, raw := range blocks {
-		compressed, err := compressLZO1XLiteral(raw)
+		if include != nil && !include[blockNo] {
+			continue
+		}
+		compressed, err := compressBlock(raw)
 		if err != nil {
 			return nil, fmt.Errorf("compress block %d: %w", blockNo, err)
 		}
@@ -56,6 +93,68 @@ func EncodePixelsToAPDUs(profile Profile, pixels []uint8, maxFragment int) ([][]
 	return apdus, nil
 }
 
+// compressBlock LZO1X-compresses a packed block, keeping the literal-only
+// stream as a fallback when the match finder does not produce a smaller one.
+func compressBlock(raw []byte) ([]byte, error) {
+	literal, err := compressLZO1XLiteral(raw)
+	if err != nil {
+		return nil, err
+	}
+	if matched := compressLZO1X1(raw); len(matched) < len(literal) {
+		return matched, nil
+	}
+	return literal, nil
+}
+
+// DecodeAPDUsToPixels reverses EncodePixelsToAPDUs: it reassembles F0D3
+// fragments per block, LZO1X-decompresses them and unpacks indexed pixels.
+func DecodeAPDUsToPixels(profile Profile, apdus [][]byte) ([]uint8, error) {
+	blockCount := profile.BlockCount()
+	blockSize := profile.BytesPerRow() * blockRows
+	pending := make(map[int][]byte)
+	nextFrag := make(map[int]int)
+	done := make([]bool, blockCount)
+	pixels := make([]uint8, profile.Width*profile.Height)
+
+	for i, apdu := range apdus {
+		blockNo, fragNo, payload, isLast, err := parseImageDataAPDU(apdu)
+		if err != nil {
+			return nil, fmt.Errorf("apdu %d: %w", i, err)
+		}
+		if blockNo >= blockCount {
+			return nil, fmt.Errorf("apdu %d: block %d out of range (blocks=%d)", i, blockNo, blockCount)
+		}
+		if done[blockNo] {
+			return nil, fmt.Errorf("apdu %d: block %d already complete", i, blockNo)
+		}
+		if fragNo != nextFrag[blockNo] {
+			return nil, fmt.Errorf("apdu %d: block %d fragment out of order: got %d want %d", i, blockNo, fragNo, nextFrag[blockNo])
+		}
+		pending[blockNo] = append(pending[blockNo], payload...)
+		nextFrag[blockNo] = fragNo + 1
+		if !isLast {
+			continue
+		}
+
+		raw, err := decompressLZO1X(pending[blockNo], blockSize)
+		if err != nil {
+			return nil, fmt.Errorf("decompress block %d: %w", blockNo, err)
+		}
+		if err := unpackBlockToRows(profile, raw, pixels, blockNo); err != nil {
+			return nil, err
+		}
+		delete(pending, blockNo)
+		done[blockNo] = true
+	}
+
+	for b, ok := range done {
+		if !ok {
+			return nil, fmt.Errorf("block %d missing or incomplete", b)
+		}
+	}
+	return pixels, nil
+}
+
 // QuantizeImageToPixels resizes/crops to panel size and quantizes to indexed colors.
 func QuantizeImageToPixels(profile Profile, img image.Image) []uint8 {
 	return QuantizeImageToPixelsWithOptions(profile, img, ImageEncodeOptions{})
@@ -397,3 +496,53 @@ func splitBytes(src []byte, n int) [][]byte {
 	}
 	return out
 }
+
+func unpackBlockToRows(profile Profile, block []byte, pixels []uint8, blockNo int) error {
+	bytesPerRow := profile.BytesPerRow()
+	if len(block) != bytesPerRow*blockRows {
+		return fmt.Errorf("block %d size: got %d, want %d", blockNo, len(block), bytesPerRow*blockRows)
+	}
+	for by := 0; by < blockRows; by++ {
+		y := blockNo*blockRows + by
+		if y >= profile.Height {
+			break
+		}
+		packed := block[by*bytesPerRow : (by+1)*bytesPerRow]
+		unpackRowRightToLeft(profile, packed, pixels[y*profile.Width:(y+1)*profile.Width])
+	}
+	return nil
+}
+
+func unpackRowRightToLeft(profile Profile, packed []byte, row []uint8) {
+	if profile.BitsPerPixel == 1 {
+		unpackRow1bppRightToLeft(profile, packed, row)
+		return
+	}
+	unpackRow2bppRightToLeft(profile, packed, row)
+}
+
+func unpackRow1bppRightToLeft(profile Profile, packed []byte, row []uint8) {
+	pixel := 0
+	for _, v := range packed {
+		for bit := 0; bit < 8; bit++ {
+			x := profile.Width - 1 - pixel
+			if x >= 0 && x < len(row) {
+				row[x] = (v >> uint(bit)) & 0x01
+			}
+			pixel++
+		}
+	}
+}
+
+func unpackRow2bppRightToLeft(profile Profile, packed []byte, row []uint8) {
+	pixel := 0
+	for _, v := range packed {
+		for nib := 0; nib < 4; nib++ {
+			x := profile.Width - 1 - pixel
+			if x >= 0 && x < len(row) {
+				row[x] = (v >> uint(6-2*nib)) & 0x03
+			}
+			pixel++
+		}
+	}
+}
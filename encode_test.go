@@ -104,3 +104,56 @@ func TestQuantizeImageToPixelsWithOptionsDither(t *testing.T) {
 		t.Fatalf("expected dither output to contain both black and white, black=%d white=%d", blackWithDither, whiteWithDither)
 	}
 }
+
+func TestDecodeAPDUsToPixelsRoundTrip(t *testing.T) {
+	for _, product := range []Product{Product29Mono, Product29Quad, Product42Mono, Product42Quad} {
+		prof := PresetProfiles[product]
+		pixels := make([]uint8, prof.Width*prof.Height)
+		for i := range pixels {
+			x := i % prof.Width
+			y := i / prof.Width
+			pixels[i] = uint8((x/7 + y/3 + x*y%5) % prof.Colors())
+		}
+		for _, maxFragment := range []int{250, 61} {
+			apdus, err := EncodePixelsToAPDUs(prof, pixels, maxFragment)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := DecodeAPDUsToPixels(prof, apdus)
+			if err != nil {
+				t.Fatalf("%s/%d: decode: %v", product, maxFragment, err)
+			}
+			for i := range pixels {
+				if got[i] != pixels[i] {
+					t.Fatalf("%s/%d: pixel %d: got %d want %d", product, maxFragment, i, got[i], pixels[i])
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeAPDUsToPixelsErrors(t *testing.T) {
+	prof := PresetProfiles[Product29Mono]
+	pixels := make([]uint8, prof.Width*prof.Height)
+	for i := range pixels {
+		pixels[i] = uint8(i*13%7) & 1
+	}
+	apdus, err := EncodePixelsToAPDUs(prof, pixels, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if apdus[0][3] != 0x00 {
+		t.Fatal("expected block 0 to span multiple fragments")
+	}
+
+	if _, err := DecodeAPDUsToPixels(prof, apdus[:len(apdus)-1]); err == nil {
+		t.Fatal("expected error for truncated stream")
+	}
+	swapped := append([][]byte{apdus[1], apdus[0]}, apdus[2:]...)
+	if _, err := DecodeAPDUsToPixels(prof, swapped); err == nil {
+		t.Fatal("expected error for out-of-order fragments")
+	}
+	if _, err := DecodeAPDUsToPixels(prof, [][]byte{apduStartRefresh}); err == nil {
+		t.Fatal("expected error for non-image apdu")
+	}
+}
@@ -0,0 +1,125 @@
+package ezsignnfc
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrClosed is returned by operations on a closed Device.
+	ErrClosed = errors.New("device closed")
+	// ErrAuthFailed reports that the tag rejected the VERIFY command.
+	ErrAuthFailed = errors.New("authentication failed")
+	// ErrWrongPIN reports that the tag rejected the PIN; see StatusError.RetriesLeft.
+	ErrWrongPIN = errors.New("wrong pin")
+	// ErrPINBlocked reports that no PIN retries are left.
+	ErrPINBlocked = errors.New("pin blocked")
+	// ErrUnknownProduct reports that the tag could not be mapped to a product.
+	ErrUnknownProduct = errors.New("unknown product")
+	// ErrCardRemoved reports that the card left the reader field.
+	ErrCardRemoved = errors.New("card removed")
+	// ErrRefreshTimeout reports that the panel did not finish refreshing in time.
+	ErrRefreshTimeout = errors.New("refresh timeout")
+	// ErrUnexpectedRefreshStatus reports an unknown F0DE refresh status byte.
+	ErrUnexpectedRefreshStatus = errors.New("unexpected refresh status")
+	// ErrTimeout matches every TimeoutError.
+	ErrTimeout = errors.New("timeout")
+	// ErrInvalidPayload reports a malformed or corrupted .ezsign payload.
+	ErrInvalidPayload = errors.New("invalid ezsign payload")
+	// ErrRelayAuth is returned when a relay server rejects the token.
+	ErrRelayAuth = errors.New("relay authentication failed")
+	// ErrTagChanged reports that a different tag was placed on the reader
+	// during a write that cannot be restarted from block 0.
+	ErrTagChanged = errors.New("tag changed during write")
+)
+
+// Command names used in StatusError.Command and APDU log records.
+const (
+	CommandAuthenticate = "authenticate"
+	CommandChangePIN    = "change-pin"
+	CommandGetUID       = "get-uid"
+	CommandImageData    = "image-data"
+	CommandStartRefresh = "start-refresh"
+	CommandPollStatus   = "poll"
+	CommandRaw          = "raw"
+)
+
+// StatusError is returned when the tag answers with a status word other than 9000.
+type StatusError struct {
+	SW1     byte
+	SW2     byte
+	Command string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status %02X%02X", e.SW1, e.SW2)
+}
+
+// SW returns the status word as a single value, e.g. 0x6982.
+func (e *StatusError) SW() uint16 {
+	return uint16(e.SW1)<<8 | uint16(e.SW2)
+}
+
+// RetriesLeft returns the remaining PIN tries encoded in a 63Cx status word.
+func (e *StatusError) RetriesLeft() (int, bool) {
+	if e.SW1 == 0x63 && e.SW2&0xF0 == 0xC0 {
+		return int(e.SW2 & 0x0F), true
+	}
+	return 0, false
+}
+
+// Is reports whether the status word belongs to a sentinel error class.
+func (e *StatusError) Is(target error) bool {
+	if e.Command != CommandAuthenticate && e.Command != CommandChangePIN {
+		return false
+	}
+	switch target {
+	case ErrAuthFailed:
+		return isAuthFailureSW(e.SW1, e.SW2)
+	case ErrWrongPIN:
+		return e.SW1 == 0x63
+	case ErrPINBlocked:
+		retries, ok := e.RetriesLeft()
+		return (ok && retries == 0) || (e.SW1 == 0x69 && e.SW2 == 0x83)
+	}
+	return false
+}
+
+func isAuthFailureSW(sw1, sw2 byte) bool {
+	if sw1 == 0x63 {
+		return true
+	}
+	return sw1 == 0x69 && (sw2 == 0x82 || sw2 == 0x83 || sw2 == 0x84)
+}
+
+// TimeoutError is returned when a command did not complete in time. The
+// pending transmit has been cancelled and the card reset.
+type TimeoutError struct {
+	// Command is empty when the operation deadline expired between commands.
+	Command string
+	Limit   time.Duration
+	// Operation is set when the whole-operation deadline expired rather
+	// than the per-APDU timeout.
+	Operation bool
+}
+
+func (e *TimeoutError) Error() string {
+	if e.Operation && e.Command == "" {
+		return fmt.Sprintf("operation timed out after %s", e.Limit)
+	}
+	if e.Operation {
+		return fmt.Sprintf("%s: operation timed out after %s", e.Command, e.Limit)
+	}
+	return fmt.Sprintf("%s: no response within %s", e.Command, e.Limit)
+}
+
+// Is reports whether target is ErrTimeout.
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+// Timeout reports true, like net.Error.
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	ezsignnfc "github.com/hrntknr/ez-sign-nfc-go"
+)
+
+func main() {
+	var (
+		listen    = flag.String("listen", ":7532", "TCP address to listen on")
+		token     = flag.String("token", "", "shared token clients must present (default: $EZSIGN_RELAY_TOKEN)")
+		reader    = flag.String("reader", "", "reader: NAME | index:N | contains:SUB | regex:RE | atr:PATTERN | card (default: first reader)")
+		exclusive = flag.Bool("exclusive", false, "open the card in exclusive share mode")
+		handshake = flag.Duration("handshake-timeout", 10*time.Second, "drop clients that do not authenticate within this duration (0: no limit)")
+		idle      = flag.Duration("idle-timeout", time.Minute, "drop clients that send nothing for this duration (0: no limit)")
+	)
+	flag.Parse()
+
+	if *token == "" {
+		*token = os.Getenv("EZSIGN_RELAY_TOKEN")
+	}
+	if *token == "" {
+		exitf("-token or EZSIGN_RELAY_TOKEN is required")
+	}
+
+	var opts []ezsignnfc.Option
+	if strings.TrimSpace(*reader) != "" {
+		sel, err := ezsignnfc.ParseReaderSelector(*reader)
+		if err != nil {
+			exitf("invalid -reader: %v", err)
+		}
+		opts = append(opts, sel)
+	}
+	if *exclusive {
+		opts = append(opts, ezsignnfc.WithShareMode(ezsignnfc.ShareExclusive))
+	}
+	t, err := ezsignnfc.OpenReader(opts...)
+	if err != nil {
+		exitf("open reader: %v", err)
+	}
+	defer t.Close()
+
+	srv, err := ezsignnfc.NewRelayServer(t, *token)
+	if err != nil {
+		exitf("relay: %v", err)
+	}
+	if err := srv.SetTimeouts(*handshake, *idle); err != nil {
+		exitf("relay: %v", err)
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	srv.SetLogger(logger)
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		exitf("listen: %v", err)
+	}
+	logger.Info("relay listening", slog.String("addr", ln.Addr().String()), slog.String("reader", t.ReaderName()))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := srv.Serve(ctx, ln); err != nil {
+		exitf("serve: %v", err)
+	}
+}
+
+func exitf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type apduTransmitter interface {
+	Transmit(ctx context.Context, apdu []byte) ([]byte, uint16, error)
+}
+
+// runAPDUShell reads hex APDUs line by line from in, sends them and prints
+// the responses to out. Blank lines and lines starting with '#' are skipped;
+// "quit" or "exit" ends the shell. A prompt is printed only when prompt is set.
+func runAPDUShell(ctx context.Context, dev apduTransmitter, in io.Reader, out io.Writer, prompt bool) error {
+	sc := bufio.NewScanner(in)
+	lineNo := 0
+	for {
+		if prompt {
+			fmt.Fprint(out, "apdu> ")
+		}
+		if !sc.Scan() {
+			break
+		}
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if line == "" {
+			continue
+		}
+		switch strings.ToLower(line) {
+		case "quit", "exit":
+			return nil
+		}
+		apdu, err := parseHexAPDU(line)
+		if err != nil {
+			if prompt {
+				fmt.Fprintf(out, "error: %v\n", err)
+				continue
+			}
+			return fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		if !prompt {
+			fmt.Fprintf(out, "> %X\n", apdu)
+		}
+		data, sw, err := dev.Transmit(ctx, apdu)
+		if err != nil {
+			if prompt {
+				fmt.Fprintf(out, "error: %v\n", err)
+				continue
+			}
+			return fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		if len(data) > 0 {
+			fmt.Fprintf(out, "< %X %04X\n", data, sw)
+		} else {
+			fmt.Fprintf(out, "< %04X\n", sw)
+		}
+	}
+	if prompt {
+		fmt.Fprintln(out)
+	}
+	return sc.Err()
+}
+
+// parseHexAPDU accepts hex digits optionally separated by spaces or colons.
+func parseHexAPDU(s string) ([]byte, error) {
+	s = strings.NewReplacer(" ", "", "\t", "", ":", "").Replace(s)
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex apdu: %w", err)
+	}
+	if len(b) < 4 {
+		return nil, fmt.Errorf("apdu too short: %d bytes", len(b))
+	}
+	return b, nil
+}
@@ -9,8 +9,10 @@ import (
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
+	"log/slog"
 	"math/rand"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
 	"time"
@@ -20,12 +22,27 @@ import (
 
 func main() {
 	var (
-		mode         = flag.String("mode", "image", "image | random | checker | hstripe | vstripe")
+		mode         = flag.String("mode", "image", "image | random | checker | hstripe | vstripe | change-pin | apdu | encode | flash")
 		product      = flag.String("product", string(ezsignnfc.Product42Quad), "2.9-2c | 2.9-4c | 4.2-2c | 4.2-4c")
-		reader       = flag.String("reader", "", "PC/SC reader name (default: first reader)")
-		inputPath    = flag.String("input", "", "input image path (required in image mode)")
+		reader       = flag.String("reader", "", "reader: NAME | index:N | contains:SUB | regex:RE | atr:PATTERN | card (default: first reader)")
+		inputPath    = flag.String("input", "", "input image path (image and encode modes) or .ezsign file (flash mode)")
+		outputPath   = flag.String("output", "", "output .ezsign path (encode mode)")
+		fragment     = flag.Int("fragment", 250, "max image data fragment size in bytes")
 		crop         = flag.String("crop", "", "crop rectangle x,y,w,h before resize")
 		dither       = flag.Bool("dither", false, "enable dithering in image mode")
+		progress     = flag.Bool("progress", false, "print write progress to stderr")
+		tracePath    = flag.String("trace", "", "record APDU trace to this file")
+		pinHex       = flag.String("pin", "", "tag PIN as 8 hex digits (default: factory PIN)")
+		newPINHex    = flag.String("new-pin", "", "new tag PIN as 8 hex digits (change-pin mode)")
+		scriptPath   = flag.String("script", "", "read APDUs from this file instead of stdin (apdu mode)")
+		logLevel     = flag.String("log", "", "log APDUs to stderr: info | debug (debug adds hex dumps)")
+		pn532Path    = flag.String("pn532", "", "use a PN532 on this serial port instead of PC/SC")
+		pn532Baud    = flag.Int("pn532-baud", 115200, "PN532 serial baud rate")
+		relayAddr    = flag.String("relay", "", "use the reader of an ezsign-relay server at host:port ($EZSIGN_RELAY_TOKEN)")
+		apduTimeout  = flag.Duration("apdu-timeout", 0, "abort an APDU that gets no response within this duration (0: no limit)")
+		opTimeout    = flag.Duration("timeout", 0, "abort a write that takes longer than this duration (0: no limit)")
+		exclusive    = flag.Bool("exclusive", false, "open the card in exclusive share mode")
+		wait         = flag.Bool("wait", false, "wait for a card to be placed on the reader")
 		seed         = flag.Int64("seed", time.Now().UnixNano(), "random seed for random mode")
 		pollMs       = flag.Int("poll-ms", 500, "refresh poll interval milliseconds")
 		pollAttempts = flag.Int("poll-attempts", 60, "max refresh poll attempts")
@@ -37,39 +54,150 @@ func main() {
 		exitf("invalid product: %v", err)
 	}
 
+	modeName := strings.ToLower(strings.TrimSpace(*mode))
+	if modeName == "encode" {
+		// Encoding runs offline, e.g. on a build server; no reader is opened.
+		if *inputPath == "" || *outputPath == "" {
+			exitf("-input and -output are required for encode mode")
+		}
+		img, err := loadInputImage(*inputPath, *crop)
+		if err != nil {
+			exitf("%v", err)
+		}
+		payload, err := ezsignnfc.EncodeImagePayload(profile, img, *fragment, ezsignnfc.ImageEncodeOptions{Dither: *dither})
+		if err != nil {
+			exitf("encode image: %v", err)
+		}
+		if err := writePayloadFile(*outputPath, payload); err != nil {
+			exitf("write payload: %v", err)
+		}
+		fmt.Printf("wrote %s (%s, %d apdus, sha256 %X)\n", *outputPath, profile.Product, len(payload.APDUs), payload.Hash())
+		return
+	}
+	var payload *ezsignnfc.EncodedPayload
+	if modeName == "flash" {
+		if *inputPath == "" {
+			exitf("-input is required for flash mode")
+		}
+		payload, err = readPayloadFile(*inputPath)
+		if err != nil {
+			exitf("read payload: %v", err)
+		}
+		// The payload decides the panel; -product is ignored.
+		profile, err = ezsignnfc.ProfileByProduct(payload.Profile.Product)
+		if err != nil {
+			exitf("payload product: %v", err)
+		}
+	}
+
+	var opts []ezsignnfc.Option
+	if strings.TrimSpace(*reader) != "" {
+		sel, err := ezsignnfc.ParseReaderSelector(*reader)
+		if err != nil {
+			exitf("invalid -reader: %v", err)
+		}
+		opts = append(opts, sel)
+	}
+	if *exclusive {
+		opts = append(opts, ezsignnfc.WithShareMode(ezsignnfc.ShareExclusive))
+	}
+	poll, err := ezsignnfc.FixedPoll(time.Duration(*pollMs)*time.Millisecond, *pollAttempts)
+	if err != nil {
+		exitf("invalid polling options: %v", err)
+	}
+	opts = append(opts, ezsignnfc.WithPollStrategy(poll))
+	opts = append(opts, ezsignnfc.WithMaxFragment(*fragment))
+	opts = append(opts, ezsignnfc.WithTimeouts(*apduTimeout, *opTimeout))
+	if *logLevel != "" {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+			exitf("invalid -log: %v", err)
+		}
+		logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+		opts = append(opts, ezsignnfc.WithLogger(logger))
+	}
+	if *pinHex != "" {
+		pin, err := ezsignnfc.ParsePIN(*pinHex)
+		if err != nil {
+			exitf("invalid -pin: %v", err)
+		}
+		opts = append(opts, ezsignnfc.WithPIN(pin))
+	}
+
+	if *pn532Path != "" {
+		if *wait || *reader != "" || *exclusive {
+			exitf("-pn532 cannot be combined with -wait, -reader or -exclusive")
+		}
+		t, err := ezsignnfc.OpenPN532(*pn532Path, *pn532Baud)
+		if err != nil {
+			exitf("open pn532: %v", err)
+		}
+		opts = append(opts, ezsignnfc.WithTransport(t))
+	}
+
+	if *relayAddr != "" {
+		if *wait || *reader != "" || *exclusive || *pn532Path != "" {
+			exitf("-relay cannot be combined with -wait, -reader, -exclusive or -pn532")
+		}
+		dialCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		t, err := ezsignnfc.DialRelay(dialCtx, *relayAddr, os.Getenv("EZSIGN_RELAY_TOKEN"))
+		cancel()
+		if err != nil {
+			exitf("connect relay: %v", err)
+		}
+		opts = append(opts, ezsignnfc.WithTransport(t))
+	}
+
 	var dev *ezsignnfc.Device
-	if strings.TrimSpace(*reader) == "" {
-		dev, err = ezsignnfc.Open(profile.Product)
+	if *wait {
+		fmt.Println("waiting for card...")
+		waitCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		dev, err = ezsignnfc.WaitForCard(waitCtx, profile.Product, opts...)
+		stop()
 	} else {
-		dev, err = ezsignnfc.Open(profile.Product, ezsignnfc.ReaderName(*reader))
+		dev, err = ezsignnfc.Open(profile.Product, opts...)
 	}
 	if err != nil {
 		exitf("open device: %v", err)
 	}
 	defer dev.Close()
 
-	if err := dev.SetPolling(time.Duration(*pollMs)*time.Millisecond, *pollAttempts); err != nil {
-		exitf("invalid polling options: %v", err)
+	if *progress {
+		dev.SetProgress(printProgress)
+	}
+	if *tracePath != "" {
+		f, err := os.Create(*tracePath)
+		if err != nil {
+			exitf("create trace: %v", err)
+		}
+		defer f.Close()
+		if _, err := dev.RecordTrace(f); err != nil {
+			exitf("record trace: %v", err)
+		}
 	}
 
 	ctx := context.Background()
 	fmt.Printf("reader: %s\n", dev.ReaderName())
 	fmt.Printf("profile: %s (%dx%d, %d colors)\n", profile.Product, profile.Width, profile.Height, profile.Colors())
+	if uid, err := dev.UID(ctx); err != nil {
+		fmt.Printf("uid: unavailable (%v)\n", err)
+	} else {
+		fmt.Printf("uid: %X\n", uid)
+	}
+	if atr, err := dev.ATR(); err != nil {
+		fmt.Printf("atr: unavailable (%v)\n", err)
+	} else {
+		fmt.Printf("atr: %X\n", atr)
+	}
 
-	switch strings.ToLower(strings.TrimSpace(*mode)) {
+	switch modeName {
 	case "image":
 		if *inputPath == "" {
 			exitf("-input is required for image mode")
 		}
-		img, err := loadImage(*inputPath)
+		img, err := loadInputImage(*inputPath, *crop)
 		if err != nil {
-			exitf("load image: %v", err)
-		}
-		if *crop != "" {
-			img, err = cropImage(img, *crop)
-			if err != nil {
-				exitf("crop image: %v", err)
-			}
+			exitf("%v", err)
 		}
 		if err := dev.WriteImageWithOptions(ctx, img, ezsignnfc.ImageEncodeOptions{Dither: *dither}); err != nil {
 			exitf("write image: %v", err)
@@ -105,6 +233,40 @@ func main() {
 		}
 		fmt.Println("write complete")
 
+	case "change-pin":
+		oldPIN := ezsignnfc.DefaultPIN
+		if *pinHex != "" {
+			oldPIN, _ = ezsignnfc.ParsePIN(*pinHex)
+		}
+		newPIN, err := ezsignnfc.ParsePIN(*newPINHex)
+		if err != nil {
+			exitf("invalid -new-pin: %v", err)
+		}
+		if err := dev.ChangePIN(ctx, oldPIN, newPIN); err != nil {
+			exitf("change pin: %v", err)
+		}
+		fmt.Println("pin changed")
+
+	case "flash":
+		if err := dev.WriteEncoded(ctx, payload); err != nil {
+			exitf("write payload: %v", err)
+		}
+		fmt.Println("write complete")
+
+	case "apdu":
+		in, prompt := os.Stdin, isTerminal(os.Stdin)
+		if *scriptPath != "" {
+			f, err := os.Open(*scriptPath)
+			if err != nil {
+				exitf("open script: %v", err)
+			}
+			defer f.Close()
+			in, prompt = f, false
+		}
+		if err := runAPDUShell(ctx, dev, in, os.Stdout, prompt); err != nil {
+			exitf("apdu: %v", err)
+		}
+
 	default:
 		exitf("unsupported mode: %s", *mode)
 	}
@@ -165,6 +327,42 @@ func generateVStripePixels(profile ezsignnfc.Profile) []uint8 {
 	return pixels
 }
 
+// loadInputImage loads the image at path and applies the optional crop spec.
+func loadInputImage(path, crop string) (image.Image, error) {
+	img, err := loadImage(path)
+	if err != nil {
+		return nil, fmt.Errorf("load image: %w", err)
+	}
+	if crop != "" {
+		img, err = cropImage(img, crop)
+		if err != nil {
+			return nil, fmt.Errorf("crop image: %w", err)
+		}
+	}
+	return img, nil
+}
+
+func writePayloadFile(path string, payload *ezsignnfc.EncodedPayload) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := payload.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readPayloadFile(path string) (*ezsignnfc.EncodedPayload, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ezsignnfc.ReadEncodedPayload(f)
+}
+
 func loadImage(path string) (image.Image, error) {
 	f, err := os.Open(path)
 	if err != nil {
@@ -211,6 +409,29 @@ func cropImage(img image.Image, spec string) (image.Image, error) {
 	return dst, nil
 }
 
+func printProgress(p ezsignnfc.Progress) {
+	elapsed := p.Elapsed.Round(time.Millisecond)
+	switch p.Phase {
+	case ezsignnfc.PhaseSendAPDU:
+		fmt.Fprintf(os.Stderr, "\r[%s] send %d/%d", elapsed, p.Index, p.Total)
+		if p.Index == p.Total {
+			fmt.Fprintln(os.Stderr)
+		}
+	case ezsignnfc.PhasePoll:
+		fmt.Fprintf(os.Stderr, "[%s] refreshing (poll %d/%d)\n", elapsed, p.Index, p.Total)
+	default:
+		fmt.Fprintf(os.Stderr, "[%s] %s\n", elapsed, p.Phase)
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	st, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return st.Mode()&os.ModeCharDevice != 0
+}
+
 func exitf(format string, args ...any) {
 	fmt.Fprintf(os.Stderr, format+"\n", args...)
 	os.Exit(1)
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"math/rand"
+	"strings"
 	"testing"
 
 	ezsignnfc "github.com/hrntknr/ez-sign-nfc-go"
@@ -57,3 +60,36 @@ func TestGeneratePatternPixelsModes(t *testing.T) {
 		})
 	}
 }
+
+type scriptedTransmitter struct {
+	sent [][]byte
+}
+
+func (s *scriptedTransmitter) Transmit(_ context.Context, apdu []byte) ([]byte, uint16, error) {
+	s.sent = append(s.sent, apdu)
+	if apdu[1] == 0xCA {
+		return []byte{0x04, 0xAA}, 0x9000, nil
+	}
+	return nil, 0x6D00, nil
+}
+
+func TestRunAPDUShellScript(t *testing.T) {
+	script := "# probe\nFF CA 00 00 00\n\nF0:E0:00:00:00 # unknown\nexit\nF0D40000\n"
+	dev := &scriptedTransmitter{}
+	var out bytes.Buffer
+	if err := runAPDUShell(context.Background(), dev, strings.NewReader(script), &out, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(dev.sent) != 2 {
+		t.Fatalf("sent %d apdus, want 2", len(dev.sent))
+	}
+	want := "> FFCA000000\n< 04AA 9000\n> F0E0000000\n< 6D00\n"
+	if out.String() != want {
+		t.Fatalf("output:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	err := runAPDUShell(context.Background(), dev, strings.NewReader("F0D4\nzz\n"), &out, false)
+	if err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Fatalf("expected line 1 error, got %v", err)
+	}
+}
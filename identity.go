@@ -0,0 +1,39 @@
+package ezsignnfc
+
+import "fmt"
+
+var apduGetUID = []byte{0xFF, 0xCA, 0x00, 0x00, 0x00}
+
+// ATRProvider is implemented by transports that know the ATR of the
+// connected card.
+type ATRProvider interface {
+	ATR() ([]byte, error)
+}
+
+func transportATR(t Transport) ([]byte, error) {
+	p, ok := t.(ATRProvider)
+	if !ok {
+		return nil, nil
+	}
+	atr, err := p.ATR()
+	if err != nil {
+		return nil, fmt.Errorf("read atr: %w", err)
+	}
+	return atr, nil
+}
+
+// transportUID reads the NFC UID with the PC/SC GET DATA pseudo-APDU.
+func transportUID(t Transport) ([]byte, error) {
+	resp, err := t.Transmit(apduGetUID)
+	if err != nil {
+		return nil, fmt.Errorf("read uid: %w", err)
+	}
+	if len(resp) < 2 {
+		return nil, fmt.Errorf("read uid: short response: %X", resp)
+	}
+	sw1, sw2 := resp[len(resp)-2], resp[len(resp)-1]
+	if sw1 != 0x90 || sw2 != 0x00 {
+		return nil, fmt.Errorf("read uid: %w", &StatusError{SW1: sw1, SW2: sw2, Command: CommandGetUID})
+	}
+	return resp[:len(resp)-2], nil
+}
@@ -0,0 +1,76 @@
+package ezsignnfc
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// logCommand records an outgoing APDU. Image data records carry the block
+// and fragment numbers; the payload hex is added only at debug level, with
+// PIN bytes redacted.
+func (d *Device) logCommand(ctx context.Context, command string, apdu []byte) {
+	if !d.logger.Enabled(ctx, slog.LevelInfo) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("dir", "out"),
+		slog.String("cmd", command),
+		slog.Int("len", len(apdu)),
+	}
+	if command == CommandImageData {
+		if block, frag, _, last, err := parseImageDataAPDU(apdu); err == nil {
+			attrs = append(attrs, slog.Int("block", block), slog.Int("frag", frag), slog.Bool("last", last))
+		}
+	}
+	if d.logger.Enabled(ctx, slog.LevelDebug) {
+		attrs = append(attrs, slog.String("data", redactedHex(command, apdu)))
+	}
+	d.logger.LogAttrs(ctx, slog.LevelInfo, "apdu", attrs...)
+}
+
+// logResponse records the response to the last command, or the transport
+// error at warn level.
+func (d *Device) logResponse(ctx context.Context, command string, resp []byte, latency time.Duration, err error) {
+	if err != nil {
+		d.logger.LogAttrs(ctx, slog.LevelWarn, "apdu failed",
+			slog.String("dir", "in"),
+			slog.String("cmd", command),
+			slog.Duration("latency", latency),
+			slog.Any("error", err))
+		return
+	}
+	if !d.logger.Enabled(ctx, slog.LevelInfo) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("dir", "in"),
+		slog.String("cmd", command),
+		slog.Int("len", len(resp)),
+	}
+	if len(resp) >= 2 {
+		attrs = append(attrs, slog.String("sw", fmt.Sprintf("%X", resp[len(resp)-2:])))
+	}
+	attrs = append(attrs, slog.Duration("latency", latency))
+	if d.logger.Enabled(ctx, slog.LevelDebug) {
+		attrs = append(attrs, slog.String("data", fmt.Sprintf("%X", resp)))
+	}
+	d.logger.LogAttrs(ctx, slog.LevelInfo, "apdu", attrs...)
+}
+
+// redactedHex formats apdu as hex, masking the data field of commands that
+// carry a PIN.
+func redactedHex(command string, apdu []byte) string {
+	pin := command == CommandAuthenticate || command == CommandChangePIN || isPINCommand(apdu)
+	if !pin || len(apdu) <= 5 {
+		return fmt.Sprintf("%X", apdu)
+	}
+	return fmt.Sprintf("%X", apdu[:5]) + strings.Repeat("**", len(apdu)-5)
+}
+
+// isPINCommand reports VERIFY and CHANGE REFERENCE DATA sent as raw APDUs.
+func isPINCommand(apdu []byte) bool {
+	return len(apdu) >= 2 && apdu[0] == 0x00 && (apdu[1] == 0x20 || apdu[1] == 0x24)
+}
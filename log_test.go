@@ -0,0 +1,65 @@
+package ezsignnfc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestDeviceAPDULogging(t *testing.T) {
+	profile := PresetProfiles[Product29Mono]
+	pixels := make([]uint8, profile.Width*profile.Height)
+
+	var info bytes.Buffer
+	dev, _ := OpenTransport(profile, NewSimulator(profile),
+		WithLogger(slog.New(slog.NewJSONHandler(&info, nil))))
+	if err := dev.WritePixels(context.Background(), pixels); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(info.String()), "\n") {
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("bad record %q: %v", line, err)
+		}
+		if rec["msg"] != "apdu" {
+			continue
+		}
+		if _, ok := rec["data"]; ok {
+			t.Fatalf("hex dump at info level: %s", line)
+		}
+		cmd, _ := rec["cmd"].(string)
+		seen[cmd+"/"+rec["dir"].(string)] = true
+		switch {
+		case rec["dir"] == "in":
+			if rec["sw"] != "9000" || rec["latency"] == nil {
+				t.Fatalf("response record: %s", line)
+			}
+		case cmd == CommandImageData:
+			if rec["block"] == nil || rec["frag"] == nil {
+				t.Fatalf("image-data record without block/frag: %s", line)
+			}
+		}
+	}
+	for _, cmd := range []string{CommandAuthenticate, CommandImageData, CommandStartRefresh, CommandPollStatus} {
+		if !seen[cmd+"/out"] || !seen[cmd+"/in"] {
+			t.Fatalf("missing records for %s: %v", cmd, seen)
+		}
+	}
+
+	var debug bytes.Buffer
+	dev.SetLogger(slog.New(slog.NewTextHandler(&debug, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	if err := dev.WritePixels(context.Background(), pixels); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(debug.String(), "data=F0D3") {
+		t.Fatalf("expected hex dump at debug level:\n%s", debug.String())
+	}
+	if strings.Contains(debug.String(), DefaultPIN.String()) {
+		t.Fatal("pin leaked into debug log")
+	}
+}
@@ -23,6 +23,110 @@ func compressLZO1XLiteral(src []byte) ([]byte, error) {
 	return out, nil
 }
 
+// compressLZO1X1 emits an LZO1X-1 stream using a hashed match finder,
+// following the reference lzo1x_1 compressor.
+func compressLZO1X1(src []byte) []byte {
+	const (
+		dictBits  = 13
+		dictSize  = 1 << dictBits
+		m2MaxLen  = 8
+		m3MaxLen  = 33
+		m4MaxLen  = 9
+		m2MaxOff  = 0x0800
+		m3MaxOff  = 0x4000
+		m4MaxOff  = 0xBFFF
+		m3Marker  = 32
+		m4Marker  = 16
+		tailBytes = 20
+	)
+
+	out := make([]byte, 0, len(src)+len(src)/16+64+3)
+	ii := 0
+
+	if len(src) > tailBytes {
+		var dict [dictSize]int
+		ipEnd := len(src) - tailBytes
+		ip := 4
+		for ip < ipEnd {
+			dv := le32(src[ip:])
+			h := ((dv * 0x1824429d) >> (32 - dictBits)) & (dictSize - 1)
+			mPos := dict[h]
+			dict[h] = ip
+			if ip-mPos > m4MaxOff || dv != le32(src[mPos:]) {
+				ip += 1 + ((ip - ii) >> 5)
+				continue
+			}
+
+			out = appendLZOLiterals(out, src[ii:ip])
+
+			mLen := 4
+			for ip+mLen < ipEnd && src[ip+mLen] == src[mPos+mLen] {
+				mLen++
+			}
+			mOff := ip - mPos
+			ip += mLen
+			ii = ip
+
+			switch {
+			case mLen <= m2MaxLen && mOff <= m2MaxOff:
+				mOff--
+				out = append(out, byte((mLen-1)<<5|(mOff&7)<<2), byte(mOff>>3))
+			case mOff <= m3MaxOff:
+				mOff--
+				if mLen <= m3MaxLen {
+					out = append(out, byte(m3Marker|(mLen-2)))
+				} else {
+					out = append(out, m3Marker)
+					out = appendLZOMulti(out, mLen-m3MaxLen)
+				}
+				out = append(out, byte(mOff<<2), byte(mOff>>6))
+			default:
+				mOff -= 0x4000
+				if mLen <= m4MaxLen {
+					out = append(out, byte(m4Marker|((mOff>>11)&8)|(mLen-2)))
+				} else {
+					out = append(out, byte(m4Marker|((mOff>>11)&8)))
+					out = appendLZOMulti(out, mLen-m4MaxLen)
+				}
+				out = append(out, byte(mOff<<2), byte(mOff>>6))
+			}
+		}
+	}
+
+	if t := len(src) - ii; t > 0 {
+		if len(out) == 0 && t <= 238 {
+			out = append(out, byte(17+t))
+			out = append(out, src[ii:]...)
+		} else {
+			out = appendLZOLiterals(out, src[ii:])
+		}
+	}
+	out = append(out, m4Marker|1, 0, 0)
+	return out
+}
+
+// appendLZOLiterals emits a literal run following a match instruction.
+// Runs of 1..3 bytes are folded into the low bits of the previous match.
+func appendLZOLiterals(out []byte, lit []byte) []byte {
+	t := len(lit)
+	switch {
+	case t == 0:
+		return out
+	case t <= 3:
+		out[len(out)-2] |= byte(t)
+	case t <= 18:
+		out = append(out, byte(t-3))
+	default:
+		out = append(out, 0)
+		out = appendLZOMulti(out, t-18)
+	}
+	return append(out, lit...)
+}
+
+func le32(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
+}
+
 func appendLZOMulti(out []byte, t int) []byte {
 	for t > 255 {
 		out = append(out, 0)
@@ -31,3 +135,187 @@ func appendLZOMulti(out []byte, t int) []byte {
 	out = append(out, byte(t))
 	return out
 }
+
+// decompressLZO1X decodes an LZO1X stream. It accepts any valid LZO1X-1
+// stream, not only the literal-only form emitted by compressLZO1XLiteral.
+// maxOut bounds the decoded size; zero or negative means unbounded.
+func decompressLZO1X(src []byte, maxOut int) ([]byte, error) {
+	if len(src) < 3 {
+		return nil, fmt.Errorf("lzo stream too short: %d", len(src))
+	}
+
+	out := make([]byte, 0, len(src)*2)
+	ip := 0
+	state := 0
+	t := 0
+
+	readByte := func() (int, error) {
+		if ip >= len(src) {
+			return 0, fmt.Errorf("lzo input overrun at %d", ip)
+		}
+		b := int(src[ip])
+		ip++
+		return b, nil
+	}
+	readMulti := func(base int) (int, error) {
+		n := 0
+		for {
+			if ip >= len(src) {
+				return 0, fmt.Errorf("lzo input overrun at %d", ip)
+			}
+			if src[ip] != 0 {
+				break
+			}
+			n += 255
+			ip++
+		}
+		b, err := readByte()
+		if err != nil {
+			return 0, err
+		}
+		return n + base + b, nil
+	}
+	readLE16 := func() (int, error) {
+		if ip+2 > len(src) {
+			return 0, fmt.Errorf("lzo input overrun at %d", ip)
+		}
+		v := int(src[ip]) | int(src[ip+1])<<8
+		ip += 2
+		return v, nil
+	}
+	copyLiterals := func(n int) error {
+		if ip+n > len(src) {
+			return fmt.Errorf("lzo literal run overruns input: need %d at %d", n, ip)
+		}
+		if maxOut > 0 && len(out)+n > maxOut {
+			return fmt.Errorf("lzo output overrun: limit %d", maxOut)
+		}
+		out = append(out, src[ip:ip+n]...)
+		ip += n
+		return nil
+	}
+	copyMatch := func(dist, n int) error {
+		pos := len(out) - dist
+		if pos < 0 {
+			return fmt.Errorf("lzo lookbehind overrun: distance %d at output %d", dist, len(out))
+		}
+		if maxOut > 0 && len(out)+n > maxOut {
+			return fmt.Errorf("lzo output overrun: limit %d", maxOut)
+		}
+		for i := 0; i < n; i++ {
+			out = append(out, out[pos+i])
+		}
+		return nil
+	}
+
+	if src[0] > 17 {
+		ip++
+		t = int(src[0]) - 17
+		if err := copyLiterals(t); err != nil {
+			return nil, err
+		}
+		if t < 4 {
+			state = t
+		} else {
+			state = 4
+		}
+	}
+
+	for {
+		var err error
+		if t, err = readByte(); err != nil {
+			return nil, err
+		}
+
+		var dist, n, next int
+		switch {
+		case t < 16 && state == 0:
+			// Long literal run.
+			if t == 0 {
+				if t, err = readMulti(15); err != nil {
+					return nil, err
+				}
+			}
+			if err := copyLiterals(t + 3); err != nil {
+				return nil, err
+			}
+			state = 4
+			continue
+		case t < 16 && state != 4:
+			// M1: 2-byte match directly after a short literal run.
+			b, err := readByte()
+			if err != nil {
+				return nil, err
+			}
+			next = t & 3
+			dist = 1 + (t >> 2) + (b << 2)
+			n = 2
+		case t < 16:
+			// M1: 3-byte match after a long literal run.
+			b, err := readByte()
+			if err != nil {
+				return nil, err
+			}
+			next = t & 3
+			dist = 1 + 0x0800 + (t >> 2) + (b << 2)
+			n = 3
+		case t >= 64:
+			// M2
+			b, err := readByte()
+			if err != nil {
+				return nil, err
+			}
+			next = t & 3
+			dist = 1 + ((t >> 2) & 7) + (b << 3)
+			n = (t >> 5) + 1
+		case t >= 32:
+			// M3
+			n = (t & 31) + 2
+			if n == 2 {
+				if n, err = readMulti(31); err != nil {
+					return nil, err
+				}
+				n += 2
+			}
+			v, err := readLE16()
+			if err != nil {
+				return nil, err
+			}
+			dist = 1 + (v >> 2)
+			next = v & 3
+		default:
+			// M4 or end-of-stream marker.
+			n = (t & 7) + 2
+			if n == 2 {
+				if n, err = readMulti(7); err != nil {
+					return nil, err
+				}
+				n += 2
+			}
+			v, err := readLE16()
+			if err != nil {
+				return nil, err
+			}
+			dist = ((t & 8) << 11) + (v >> 2)
+			next = v & 3
+			if dist == 0 {
+				if n != 3 {
+					return nil, fmt.Errorf("lzo malformed end marker")
+				}
+				if ip != len(src) {
+					return nil, fmt.Errorf("lzo trailing input: %d bytes", len(src)-ip)
+				}
+				return out, nil
+			}
+			dist += 0x4000
+		}
+
+		if err := copyMatch(dist, n); err != nil {
+			return nil, err
+		}
+		state = next
+		if err := copyLiterals(next); err != nil {
+			return nil, err
+		}
+	}
+}
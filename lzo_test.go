@@ -0,0 +1,81 @@
+package ezsignnfc
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestLZO1X1RoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := make([]byte, 5000)
+	rng.Read(random)
+	runs := make([]byte, 70000)
+	for i := range runs {
+		runs[i] = byte(i / 300)
+	}
+	sparse := bytes.Repeat([]byte{0x55}, 2000)
+	for i := 0; i < len(sparse); i += 97 {
+		sparse[i] = byte(rng.Intn(256))
+	}
+
+	tests := []struct {
+		name string
+		src  []byte
+	}{
+		{"empty", nil},
+		{"short", []byte{1, 2, 3}},
+		{"tail-only", bytes.Repeat([]byte{0xAA}, 20)},
+		{"uniform", bytes.Repeat([]byte{0xFF}, 2000)},
+		{"random", random},
+		{"runs", runs},
+		{"sparse", sparse},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			compressed := compressLZO1X1(tc.src)
+			got, err := decompressLZO1X(compressed, 0)
+			if err != nil {
+				t.Fatalf("decompress: %v", err)
+			}
+			if !bytes.Equal(got, tc.src) {
+				t.Fatalf("round trip mismatch: got %d bytes want %d", len(got), len(tc.src))
+			}
+		})
+	}
+}
+
+func TestLZO1XLiteralRoundTrip(t *testing.T) {
+	for _, n := range []int{4, 238, 239, 2000} {
+		src := make([]byte, n)
+		for i := range src {
+			src[i] = byte(i * 31)
+		}
+		compressed, err := compressLZO1XLiteral(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := decompressLZO1X(compressed, 0)
+		if err != nil {
+			t.Fatalf("len %d: decompress: %v", n, err)
+		}
+		if !bytes.Equal(got, src) {
+			t.Fatalf("len %d: round trip mismatch", n)
+		}
+	}
+}
+
+func TestEncodePixelsToAPDUsCompressesUniformPanel(t *testing.T) {
+	profile := PresetProfiles[Product42Quad]
+	pixels := make([]uint8, profile.Width*profile.Height)
+	for i := range pixels {
+		pixels[i] = ColorWhite
+	}
+	apdus, err := EncodePixelsToAPDUs(profile, pixels, 250)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(apdus) != profile.BlockCount() {
+		t.Fatalf("uniform panel apdu count: got %d want %d", len(apdus), profile.BlockCount())
+	}
+}
@@ -0,0 +1,267 @@
+package ezsignnfc
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+// ShareMode controls whether other applications may use the card while the
+// device is open.
+type ShareMode int
+
+const (
+	// ShareShared lets other applications connect to the card too.
+	ShareShared ShareMode = iota
+	// ShareExclusive keeps the card to this device, e.g. for kiosks.
+	ShareExclusive
+)
+
+// Protocol is the card protocol negotiated on connect.
+type Protocol int
+
+const (
+	ProtocolAny Protocol = iota
+	ProtocolT0
+	ProtocolT1
+)
+
+// Option configures Open, OpenAuto, WaitForCard and OpenTransport.
+// Every ReaderSelector is also an Option.
+type Option interface {
+	applyOption(c *openConfig)
+}
+
+type optionFunc func(c *openConfig)
+
+func (f optionFunc) applyOption(c *openConfig) { f(c) }
+
+// openConfig collects options before they are validated as a whole.
+type openConfig struct {
+	selectors    []ReaderSelector
+	share        ShareMode
+	protocol     Protocol
+	pcscSet      bool
+	maxFragment  int
+	pollStrategy PollStrategy
+	pollSet      bool
+	pin          *PIN
+	logger       *slog.Logger
+	loggerSet    bool
+	transport    Transport
+	transportSet bool
+	apduTimeout  time.Duration
+	opTimeout    time.Duration
+}
+
+// WithReader selects the PC/SC reader. Passing the selector directly is
+// equivalent.
+func WithReader(selector ReaderSelector) Option {
+	return optionFunc(func(c *openConfig) {
+		c.selectors = append(c.selectors, selector)
+	})
+}
+
+// WithShareMode sets the PC/SC share mode. The default is ShareShared.
+func WithShareMode(mode ShareMode) Option {
+	return optionFunc(func(c *openConfig) {
+		c.share = mode
+		c.pcscSet = true
+	})
+}
+
+// WithProtocol sets the card protocol. The default is ProtocolAny.
+func WithProtocol(protocol Protocol) Option {
+	return optionFunc(func(c *openConfig) {
+		c.protocol = protocol
+		c.pcscSet = true
+	})
+}
+
+// WithMaxFragment sets the image data fragment size, as SetMaxFragment.
+func WithMaxFragment(n int) Option {
+	return optionFunc(func(c *openConfig) {
+		c.maxFragment = n
+	})
+}
+
+// WithPollStrategy sets the refresh polling strategy, as SetPollStrategy.
+func WithPollStrategy(strategy PollStrategy) Option {
+	return optionFunc(func(c *openConfig) {
+		c.pollStrategy = strategy
+		c.pollSet = true
+	})
+}
+
+// WithPIN sets the PIN used to authenticate, as SetPIN.
+func WithPIN(pin PIN) Option {
+	return optionFunc(func(c *openConfig) {
+		c.pin = &pin
+	})
+}
+
+// WithLogger sets the logger for device events.
+func WithLogger(logger *slog.Logger) Option {
+	return optionFunc(func(c *openConfig) {
+		c.logger = logger
+		c.loggerSet = true
+	})
+}
+
+// WithTimeouts sets the per-APDU and whole-operation timeouts, as SetTimeouts.
+func WithTimeouts(apdu, operation time.Duration) Option {
+	return optionFunc(func(c *openConfig) {
+		c.apduTimeout = apdu
+		c.opTimeout = operation
+	})
+}
+
+// WithTransport opens the device on transport instead of a PC/SC reader.
+// It cannot be combined with reader, share mode or protocol options.
+func WithTransport(transport Transport) Option {
+	return optionFunc(func(c *openConfig) {
+		c.transport = transport
+		c.transportSet = true
+	})
+}
+
+func (s readerIndexSelector) applyOption(c *openConfig)    { c.selectors = append(c.selectors, s) }
+func (s readerNameSelector) applyOption(c *openConfig)     { c.selectors = append(c.selectors, s) }
+func (s readerContainsSelector) applyOption(c *openConfig) { c.selectors = append(c.selectors, s) }
+func (s readerRegexpSelector) applyOption(c *openConfig)   { c.selectors = append(c.selectors, s) }
+func (s readerATRSelector) applyOption(c *openConfig)      { c.selectors = append(c.selectors, s) }
+func (s readerWithCardSelector) applyOption(c *openConfig) { c.selectors = append(c.selectors, s) }
+
+// newOpenConfig applies opts and validates the result.
+func newOpenConfig(opts []Option) (*openConfig, error) {
+	c := &openConfig{}
+	for _, opt := range opts {
+		if opt == nil {
+			return nil, fmt.Errorf("option must not be nil")
+		}
+		opt.applyOption(c)
+	}
+	if err := checkSelectors(c.selectors); err != nil {
+		return nil, err
+	}
+	switch c.share {
+	case ShareShared, ShareExclusive:
+	default:
+		return nil, fmt.Errorf("unknown share mode: %d", c.share)
+	}
+	switch c.protocol {
+	case ProtocolAny, ProtocolT0, ProtocolT1:
+	default:
+		return nil, fmt.Errorf("unknown protocol: %d", c.protocol)
+	}
+	if c.maxFragment != 0 {
+		if err := checkMaxFragment(c.maxFragment); err != nil {
+			return nil, err
+		}
+	}
+	if c.pollSet && c.pollStrategy == nil {
+		return nil, fmt.Errorf("poll strategy must not be nil")
+	}
+	if err := checkTimeouts(c.apduTimeout, c.opTimeout); err != nil {
+		return nil, err
+	}
+	if c.loggerSet && c.logger == nil {
+		return nil, fmt.Errorf("logger must not be nil")
+	}
+	if c.transportSet {
+		if c.transport == nil {
+			return nil, fmt.Errorf("transport must not be nil")
+		}
+		if len(c.selectors) > 0 || c.pcscSet {
+			return nil, fmt.Errorf("transport option cannot be combined with reader, share mode or protocol")
+		}
+	}
+	return c, nil
+}
+
+// requirePCSC rejects options that only make sense with a caller-supplied
+// transport.
+func (c *openConfig) requirePCSC(name string) error {
+	if c.transportSet {
+		return fmt.Errorf("%s does not accept a transport option", name)
+	}
+	return nil
+}
+
+// requireTransport rejects PC/SC options when the transport is given
+// separately.
+func (c *openConfig) requireTransport(name string) error {
+	if len(c.selectors) > 0 || c.pcscSet || c.transportSet {
+		return fmt.Errorf("%s accepts no reader, share mode, protocol or transport option", name)
+	}
+	return nil
+}
+
+// requireReaderOnly rejects options that configure a Device.
+func (c *openConfig) requireReaderOnly(name string) error {
+	if err := c.requirePCSC(name); err != nil {
+		return err
+	}
+	if c.maxFragment != 0 || c.pollSet || c.pin != nil || c.loggerSet || c.apduTimeout != 0 || c.opTimeout != 0 {
+		return fmt.Errorf("%s accepts only reader, share mode and protocol options", name)
+	}
+	return nil
+}
+
+// openTransport returns the configured transport or connects to a PC/SC reader.
+func (c *openConfig) openTransport() (Transport, error) {
+	if c.transport != nil {
+		return c.transport, nil
+	}
+	return openSCardTransport(c)
+}
+
+// configure copies device settings onto d.
+func (c *openConfig) configure(d *Device) {
+	if c.maxFragment != 0 {
+		d.maxFragment = c.maxFragment
+	}
+	if c.pollStrategy != nil {
+		d.pollStrategy = c.pollStrategy
+	}
+	if c.pin != nil {
+		d.pin = *c.pin
+	}
+	if c.logger != nil {
+		d.logger = c.logger
+	}
+	d.apduTimeout = c.apduTimeout
+	d.opTimeout = c.opTimeout
+	d.logger.LogAttrs(context.Background(), slog.LevelDebug, "device opened",
+		slog.String("reader", d.reader),
+		slog.String("product", string(d.profile.Product)))
+}
+
+func (m ShareMode) scard() scard.ShareMode {
+	if m == ShareExclusive {
+		return scard.ShareExclusive
+	}
+	return scard.ShareShared
+}
+
+func (p Protocol) scard() scard.Protocol {
+	switch p {
+	case ProtocolT0:
+		return scard.ProtocolT0
+	case ProtocolT1:
+		return scard.ProtocolT1
+	default:
+		return scard.ProtocolAny
+	}
+}
+
+// discardHandler drops every record; it is the default device logger.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
@@ -0,0 +1,79 @@
+package ezsignnfc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenOptions(t *testing.T) {
+	profile := PresetProfiles[Product29Mono]
+	sim := NewSimulator(profile)
+	poll, _ := FixedPoll(10*time.Millisecond, 5)
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	dev, err := Open(Product29Mono,
+		WithTransport(sim),
+		WithMaxFragment(64),
+		WithPollStrategy(poll),
+		WithPIN(PIN{0x01, 0x02, 0x03, 0x04}),
+		WithLogger(logger),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dev.Close()
+	if dev.maxFragment != 64 || dev.pollStrategy != poll {
+		t.Fatalf("options not applied: fragment=%d poll=%v", dev.maxFragment, dev.pollStrategy)
+	}
+	if !strings.Contains(logs.String(), "device opened") {
+		t.Fatalf("logger not used: %q", logs.String())
+	}
+	err = dev.WritePixels(context.Background(), make([]uint8, profile.Width*profile.Height))
+	if !errors.Is(err, ErrWrongPIN) {
+		t.Fatalf("expected ErrWrongPIN with configured pin, got %v", err)
+	}
+}
+
+func TestOpenOptionsValidation(t *testing.T) {
+	sim := NewSimulator(PresetProfiles[Product29Mono])
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "nil-option", opts: []Option{nil}},
+		{name: "two-readers", opts: []Option{ReaderIndex(0), WithReader(ReaderName("A"))}},
+		{name: "nil-reader", opts: []Option{WithReader(nil)}},
+		{name: "share-mode", opts: []Option{WithShareMode(ShareMode(7))}},
+		{name: "protocol", opts: []Option{WithProtocol(Protocol(7))}},
+		{name: "fragment", opts: []Option{WithMaxFragment(251)}},
+		{name: "nil-poll", opts: []Option{WithPollStrategy(nil)}},
+		{name: "nil-logger", opts: []Option{WithLogger(nil)}},
+		{name: "nil-transport", opts: []Option{WithTransport(nil)}},
+		{name: "transport-and-reader", opts: []Option{WithTransport(sim), ReaderIndex(0)}},
+		{name: "transport-and-share", opts: []Option{WithTransport(sim), WithShareMode(ShareExclusive)}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Open(Product29Mono, tc.opts...); err == nil {
+				t.Fatal("expected validation error")
+			}
+		})
+	}
+
+	if _, err := OpenTransport(PresetProfiles[Product29Mono], sim, ReaderIndex(0)); err == nil {
+		t.Fatal("OpenTransport must reject reader options")
+	}
+	dev, err := OpenTransport(PresetProfiles[Product29Mono], sim, WithMaxFragment(32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dev.maxFragment != 32 {
+		t.Fatalf("OpenTransport fragment: got %d", dev.maxFragment)
+	}
+}
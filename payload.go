@@ -0,0 +1,273 @@
+package ezsignnfc
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+)
+
+// .ezsign files carry APDUs encoded ahead of time, so a slow writer only has
+// to send them. All integers are big-endian:
+//
+//	magic       "EZSIGN" 0x00
+//	version     u8 (1)
+//	product     u8 length, bytes
+//	width       u16
+//	height      u16
+//	bpp         u8
+//	options     u8 flags (bit 0: dither)
+//	maxFragment u8
+//	hash        32 bytes, SHA-256 over the fields above and the APDU list below
+//	count       u16
+//	apdus       count x (u16 length, bytes)
+const (
+	payloadMagic   = "EZSIGN\x00"
+	payloadVersion = 1
+
+	payloadOptDither = 1 << 0
+)
+
+// EncodedPayload is a pre-encoded image ready for Device.WriteEncoded.
+type EncodedPayload struct {
+	Profile     Profile
+	Options     ImageEncodeOptions
+	MaxFragment int
+	APDUs       [][]byte
+}
+
+// EncodeImagePayload quantizes and encodes img like
+// EncodeImageToAPDUsWithOptions and wraps the result in a payload.
+func EncodeImagePayload(profile Profile, img image.Image, maxFragment int, opts ImageEncodeOptions) (*EncodedPayload, error) {
+	apdus, err := EncodeImageToAPDUsWithOptions(profile, img, maxFragment, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &EncodedPayload{Profile: profile, Options: opts, MaxFragment: maxFragment, APDUs: apdus}, nil
+}
+
+// EncodePixelsPayload encodes indexed pixels like EncodePixelsToAPDUs and
+// wraps the result in a payload.
+func EncodePixelsPayload(profile Profile, pixels []uint8, maxFragment int) (*EncodedPayload, error) {
+	apdus, err := EncodePixelsToAPDUs(profile, pixels, maxFragment)
+	if err != nil {
+		return nil, err
+	}
+	return &EncodedPayload{Profile: profile, MaxFragment: maxFragment, APDUs: apdus}, nil
+}
+
+// Hash returns the SHA-256 content hash of the header fields and the APDU
+// list, as stored in the file.
+func (p *EncodedPayload) Hash() [sha256.Size]byte {
+	h := sha256.New()
+	h.Write(p.header())
+	h.Write(p.body())
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// WriteTo writes p in the .ezsign format.
+func (p *EncodedPayload) WriteTo(w io.Writer) (int64, error) {
+	if err := p.validate(); err != nil {
+		return 0, err
+	}
+	var buf bytes.Buffer
+	buf.Write(p.header())
+	hash := p.Hash()
+	buf.Write(hash[:])
+	buf.Write(p.body())
+	return buf.WriteTo(w)
+}
+
+// header serializes the fields before the hash.
+func (p *EncodedPayload) header() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(payloadMagic)
+	buf.WriteByte(payloadVersion)
+	buf.WriteByte(byte(len(p.Profile.Product)))
+	buf.WriteString(string(p.Profile.Product))
+	binary.Write(&buf, binary.BigEndian, uint16(p.Profile.Width))
+	binary.Write(&buf, binary.BigEndian, uint16(p.Profile.Height))
+	buf.WriteByte(byte(p.Profile.BitsPerPixel))
+	var flags byte
+	if p.Options.Dither {
+		flags |= payloadOptDither
+	}
+	buf.WriteByte(flags)
+	buf.WriteByte(byte(p.MaxFragment))
+	return buf.Bytes()
+}
+
+// body serializes the APDU list after the hash.
+func (p *EncodedPayload) body() []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint16(len(p.APDUs)))
+	for _, apdu := range p.APDUs {
+		binary.Write(&buf, binary.BigEndian, uint16(len(apdu)))
+		buf.Write(apdu)
+	}
+	return buf.Bytes()
+}
+
+// ReadEncodedPayload reads a .ezsign payload and verifies its version,
+// content hash and APDUs.
+func ReadEncodedPayload(r io.Reader) (*EncodedPayload, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	pr := payloadReader{b: data}
+	if string(pr.next(len(payloadMagic))) != payloadMagic {
+		return nil, fmt.Errorf("%w: bad magic", ErrInvalidPayload)
+	}
+	if v := pr.u8(); v != payloadVersion {
+		if pr.err != nil {
+			return nil, pr.err
+		}
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidPayload, v)
+	}
+	p := &EncodedPayload{}
+	p.Profile.Product = Product(pr.next(int(pr.u8())))
+	p.Profile.Width = int(pr.u16())
+	p.Profile.Height = int(pr.u16())
+	p.Profile.BitsPerPixel = int(pr.u8())
+	flags := pr.u8()
+	p.Options.Dither = flags&payloadOptDither != 0
+	p.MaxFragment = int(pr.u8())
+	var hash [sha256.Size]byte
+	copy(hash[:], pr.next(sha256.Size))
+	count := int(pr.u16())
+	for i := 0; i < count && pr.err == nil; i++ {
+		p.APDUs = append(p.APDUs, append([]byte(nil), pr.next(int(pr.u16()))...))
+	}
+	if pr.err != nil {
+		return nil, pr.err
+	}
+	if len(pr.b) != 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes", ErrInvalidPayload, len(pr.b))
+	}
+	if flags&^payloadOptDither != 0 {
+		return nil, fmt.Errorf("%w: unknown option flags 0x%02X", ErrInvalidPayload, flags)
+	}
+	if p.Hash() != hash {
+		return nil, fmt.Errorf("%w: content hash mismatch", ErrInvalidPayload)
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// validate checks that every APDU is image data within the profile's blocks
+// and the declared fragment size.
+func (p *EncodedPayload) validate() error {
+	if p.Profile.Width <= 0 || p.Profile.Height <= 0 || p.Profile.Width > 0xFFFF || p.Profile.Height > 0xFFFF {
+		return fmt.Errorf("%w: invalid profile size %dx%d", ErrInvalidPayload, p.Profile.Width, p.Profile.Height)
+	}
+	if p.Profile.BitsPerPixel != 1 && p.Profile.BitsPerPixel != 2 {
+		return fmt.Errorf("%w: unsupported bits per pixel %d", ErrInvalidPayload, p.Profile.BitsPerPixel)
+	}
+	if len(p.Profile.Product) > 0xFF {
+		return fmt.Errorf("%w: product name too long", ErrInvalidPayload)
+	}
+	if err := checkMaxFragment(p.MaxFragment); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	if len(p.APDUs) == 0 || len(p.APDUs) > 0xFFFF {
+		return fmt.Errorf("%w: %d apdus", ErrInvalidPayload, len(p.APDUs))
+	}
+	blocks := (p.Profile.Height + blockRows - 1) / blockRows
+	for i, apdu := range p.APDUs {
+		block, _, frag, _, err := parseImageDataAPDU(apdu)
+		if err != nil {
+			return fmt.Errorf("%w: apdu %d: %v", ErrInvalidPayload, i, err)
+		}
+		if block >= blocks {
+			return fmt.Errorf("%w: apdu %d: block %d out of range", ErrInvalidPayload, i, block)
+		}
+		if len(frag) > p.MaxFragment {
+			return fmt.Errorf("%w: apdu %d: fragment of %d bytes exceeds %d", ErrInvalidPayload, i, len(frag), p.MaxFragment)
+		}
+	}
+	return nil
+}
+
+// checkPayload rejects payloads that do not fit this device.
+func (d *Device) checkPayload(p *EncodedPayload) error {
+	if p == nil {
+		return fmt.Errorf("payload must not be nil")
+	}
+	if err := p.validate(); err != nil {
+		return err
+	}
+	if pp := p.Profile; pp.Width != d.profile.Width || pp.Height != d.profile.Height || pp.BitsPerPixel != d.profile.BitsPerPixel {
+		return fmt.Errorf("payload for %s (%dx%d, %dbpp) does not match device profile %s (%dx%d, %dbpp)",
+			pp.Product, pp.Width, pp.Height, pp.BitsPerPixel,
+			d.profile.Product, d.profile.Width, d.profile.Height, d.profile.BitsPerPixel)
+	}
+	if p.MaxFragment > d.maxFragment {
+		return fmt.Errorf("payload fragment size %d exceeds device max fragment %d", p.MaxFragment, d.maxFragment)
+	}
+	return nil
+}
+
+// WriteEncoded writes a pre-encoded payload. The payload profile must match
+// the device and its fragments must fit the device max fragment.
+func (d *Device) WriteEncoded(ctx context.Context, p *EncodedPayload) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.checkPayload(p); err != nil {
+		return err
+	}
+	return d.writeAPDUs(ctx, p.APDUs, true)
+}
+
+// WriteEncoded writes a pre-encoded payload without authenticating again.
+func (s *Session) WriteEncoded(ctx context.Context, p *EncodedPayload) error {
+	if err := s.check(); err != nil {
+		return err
+	}
+	if err := s.d.checkPayload(p); err != nil {
+		return err
+	}
+	return s.d.writeAPDUs(ctx, p.APDUs, false)
+}
+
+// payloadReader reads big-endian fields and remembers the first short read.
+type payloadReader struct {
+	b   []byte
+	err error
+}
+
+func (r *payloadReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > len(r.b) {
+		r.err = fmt.Errorf("%w: truncated", ErrInvalidPayload)
+		return nil
+	}
+	out := r.b[:n]
+	r.b = r.b[n:]
+	return out
+}
+
+func (r *payloadReader) u8() byte {
+	b := r.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *payloadReader) u16() uint16 {
+	b := r.next(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
@@ -0,0 +1,112 @@
+package ezsignnfc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestEncodedPayloadRoundTrip(t *testing.T) {
+	profile := PresetProfiles[Product42Quad]
+	img := image.NewNRGBA(image.Rect(0, 0, profile.Width, profile.Height))
+	for y := 0; y < profile.Height; y++ {
+		for x := 0; x < profile.Width; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	opts := ImageEncodeOptions{Dither: true}
+	p, err := EncodeImagePayload(profile, img, 200, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := p.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadEncodedPayload(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Profile != profile || got.Options != opts || got.MaxFragment != 200 || got.Hash() != p.Hash() {
+		t.Fatalf("payload header mismatch: %+v %+v %d", got.Profile, got.Options, got.MaxFragment)
+	}
+
+	direct := NewSimulator(profile)
+	dev, _ := OpenTransport(profile, direct)
+	if err := dev.WriteImageWithOptions(context.Background(), img, opts); err != nil {
+		t.Fatal(err)
+	}
+	sim := NewSimulator(profile)
+	dev, _ = OpenTransport(profile, sim)
+	if err := dev.WriteEncoded(context.Background(), got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sim.Pixels(), direct.Pixels()) {
+		t.Fatal("WriteEncoded shows a different image than WriteImageWithOptions")
+	}
+}
+
+func TestReadEncodedPayloadRejectsCorruption(t *testing.T) {
+	profile := PresetProfiles[Product29Mono]
+	p, err := EncodePixelsPayload(profile, make([]uint8, profile.Width*profile.Height), 250)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	p.WriteTo(&buf)
+	valid := buf.Bytes()
+
+	mutate := func(f func(b []byte) []byte) []byte {
+		return f(append([]byte(nil), valid...))
+	}
+	// Offsets of the header fields after the product name.
+	product := len(payloadMagic) + 2
+	width := product + len(profile.Product)
+	maxFragment := width + 6
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "magic", data: mutate(func(b []byte) []byte { b[0] = 'X'; return b })},
+		{name: "version", data: mutate(func(b []byte) []byte { b[len(payloadMagic)] = 2; return b })},
+		{name: "product", data: mutate(func(b []byte) []byte { b[product+len(profile.Product)-2] = '4'; return b })},
+		{name: "width", data: mutate(func(b []byte) []byte { b[width+1]--; return b })},
+		{name: "max-fragment", data: mutate(func(b []byte) []byte { b[maxFragment]--; return b })},
+		{name: "apdu-byte", data: mutate(func(b []byte) []byte { b[len(b)-1] ^= 0xFF; return b })},
+		{name: "truncated", data: valid[:len(valid)-3]},
+		{name: "trailing", data: append(append([]byte(nil), valid...), 0x00)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ReadEncodedPayload(bytes.NewReader(tc.data)); !errors.Is(err, ErrInvalidPayload) {
+				t.Fatalf("expected ErrInvalidPayload, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWriteEncodedChecksDevice(t *testing.T) {
+	mono := PresetProfiles[Product29Mono]
+	p, err := EncodePixelsPayload(mono, make([]uint8, mono.Width*mono.Height), 250)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quad := PresetProfiles[Product29Quad]
+	dev, _ := OpenTransport(quad, NewSimulator(quad))
+	if err := dev.WriteEncoded(context.Background(), p); err == nil {
+		t.Fatal("expected profile mismatch error")
+	}
+
+	sim := NewSimulator(mono)
+	dev, _ = OpenTransport(mono, sim, WithMaxFragment(100))
+	if err := dev.WriteEncoded(context.Background(), p); err == nil {
+		t.Fatal("expected max fragment error")
+	}
+	if sim.Refreshes() != 0 {
+		t.Fatal("rejected payload must not be sent")
+	}
+}
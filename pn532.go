@@ -0,0 +1,285 @@
+package ezsignnfc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// PN532 frame identifiers and commands (NXP UM0701-02).
+const (
+	pn532HostToPN532 = 0xD4
+	pn532PN532ToHost = 0xD5
+	pn532ErrorFrame  = 0x7F
+
+	pn532CmdInDataExchange      = 0x40
+	pn532CmdInListPassiveTarget = 0x4A
+	pn532CmdRFConfiguration     = 0x32
+	pn532CmdSAMConfiguration    = 0x14
+)
+
+var pn532Ack = []byte{0x00, 0x00, 0xFF, 0x00, 0xFF, 0x00}
+
+// pn532Wakeup is sent before the first command to wake a PN532 on HSU.
+var pn532Wakeup = []byte{0x55, 0x55, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+
+// pn532ReconnectInterval is the pause between target searches in Reconnect.
+const pn532ReconnectInterval = 200 * time.Millisecond
+
+// PN532Transport sends APDUs through an NXP PN532 attached over a serial
+// port (HSU), without pcscd. The tag is selected once with
+// InListPassiveTarget and every APDU goes through InDataExchange.
+// GET DATA for the UID (FF CA 00 00 00), a PC/SC reader pseudo-APDU, is
+// answered from the selected target.
+type PN532Transport struct {
+	port    io.ReadWriteCloser
+	r       *bufio.Reader
+	name    string
+	timeout time.Duration
+	target  byte
+	uid     []byte
+}
+
+// OpenPN532 opens the PN532 on the serial device at path, e.g.
+// "/dev/ttyUSB0" at 115200 baud, and selects the tag in the field.
+func OpenPN532(path string, baud int) (*PN532Transport, error) {
+	port, err := openSerialPort(path, baud)
+	if err != nil {
+		return nil, fmt.Errorf("open serial port %q: %w", path, err)
+	}
+	t, err := NewPN532Transport(port, path)
+	if err != nil {
+		port.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// NewPN532Transport wakes the PN532 on port, configures it and selects an
+// ISO 14443-A tag. name is reported by ReaderName. The transport owns port
+// only when it is returned without error. When port has SetReadDeadline,
+// responses that do not arrive within a second fail instead of blocking.
+func NewPN532Transport(port io.ReadWriteCloser, name string) (*PN532Transport, error) {
+	t := &PN532Transport{
+		port:    port,
+		r:       bufio.NewReader(port),
+		name:    name,
+		timeout: time.Second,
+	}
+	if _, err := port.Write(pn532Wakeup); err != nil {
+		return nil, fmt.Errorf("pn532 wakeup: %w", err)
+	}
+	// Normal mode, no IRQ pin.
+	if _, err := t.call(pn532CmdSAMConfiguration, []byte{0x01, 0x14, 0x00}); err != nil {
+		return nil, fmt.Errorf("pn532 sam configuration: %w", err)
+	}
+	// MaxRetries: bound passive activation so an empty field does not block.
+	if _, err := t.call(pn532CmdRFConfiguration, []byte{0x05, 0xFF, 0x01, 0x10}); err != nil {
+		return nil, fmt.Errorf("pn532 rf configuration: %w", err)
+	}
+	found, err := t.listTarget()
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("pn532: no tag in field")
+	}
+	return t, nil
+}
+
+// Transmit sends apdu to the selected tag and returns its response with SW.
+func (t *PN532Transport) Transmit(apdu []byte) ([]byte, error) {
+	if t.port == nil {
+		return nil, fmt.Errorf("transport closed")
+	}
+	if bytes.Equal(apdu, apduGetUID) {
+		return append(append([]byte(nil), t.uid...), 0x90, 0x00), nil
+	}
+	resp, err := t.call(pn532CmdInDataExchange, append([]byte{t.target}, apdu...))
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < 1 {
+		return nil, fmt.Errorf("pn532: empty InDataExchange response")
+	}
+	if err := pn532StatusError(resp[0]); err != nil {
+		return nil, err
+	}
+	if resp[0]&0x40 != 0 {
+		return nil, fmt.Errorf("pn532: chained response not supported")
+	}
+	return resp[1:], nil
+}
+
+// Reconnect searches for a tag until one enters the field or ctx is done.
+func (t *PN532Transport) Reconnect(ctx context.Context) error {
+	if t.port == nil {
+		return fmt.Errorf("transport closed")
+	}
+	for {
+		found, err := t.listTarget()
+		if err != nil {
+			return err
+		}
+		if found {
+			return nil
+		}
+		if err := sleepContext(ctx, pn532ReconnectInterval); err != nil {
+			return err
+		}
+	}
+}
+
+func (t *PN532Transport) Close() error {
+	if t.port == nil {
+		return nil
+	}
+	err := t.port.Close()
+	t.port = nil
+	return err
+}
+
+func (t *PN532Transport) ReaderName() string {
+	return t.name
+}
+
+// listTarget runs InListPassiveTarget for one ISO 14443-A target at 106 kbps.
+func (t *PN532Transport) listTarget() (bool, error) {
+	resp, err := t.call(pn532CmdInListPassiveTarget, []byte{0x01, 0x00})
+	if err != nil {
+		return false, fmt.Errorf("pn532 list passive target: %w", err)
+	}
+	if len(resp) < 1 || resp[0] == 0 {
+		return false, nil
+	}
+	// NbTg, Tg, SENS_RES(2), SEL_RES, NFCIDLength, NFCID, ATS...
+	if len(resp) < 6 || len(resp) < 6+int(resp[5]) {
+		return false, fmt.Errorf("pn532: short target data: %X", resp)
+	}
+	t.target = resp[1]
+	t.uid = append([]byte(nil), resp[6:6+int(resp[5])]...)
+	return true, nil
+}
+
+// call sends a command frame, waits for the ACK and returns the response
+// data after the TFI and response code.
+func (t *PN532Transport) call(cmd byte, params []byte) ([]byte, error) {
+	frame := append([]byte{pn532HostToPN532, cmd}, params...)
+	if _, err := t.port.Write(encodePN532Frame(frame)); err != nil {
+		return nil, err
+	}
+	if d, ok := t.port.(interface{ SetReadDeadline(time.Time) error }); ok {
+		d.SetReadDeadline(time.Now().Add(t.timeout))
+		defer d.SetReadDeadline(time.Time{})
+	}
+	_, ack, err := readPN532Frame(t.r)
+	if err != nil {
+		return nil, fmt.Errorf("read ack: %w", err)
+	}
+	if !ack {
+		return nil, fmt.Errorf("pn532: expected ack for command 0x%02X", cmd)
+	}
+	data, ack, err := readPN532Frame(t.r)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if ack {
+		return nil, fmt.Errorf("pn532: unexpected ack for command 0x%02X", cmd)
+	}
+	if len(data) == 1 && data[0] == pn532ErrorFrame {
+		return nil, fmt.Errorf("pn532: application error for command 0x%02X", cmd)
+	}
+	if len(data) < 2 || data[0] != pn532PN532ToHost || data[1] != cmd+1 {
+		return nil, fmt.Errorf("pn532: unexpected response %X for command 0x%02X", data, cmd)
+	}
+	return data[2:], nil
+}
+
+// pn532StatusError maps the InDataExchange status byte to an error.
+// A timeout means the tag no longer answers, usually because it left the field.
+func pn532StatusError(status byte) error {
+	switch code := status & 0x3F; code {
+	case 0x00:
+		return nil
+	case 0x01:
+		return fmt.Errorf("%w: pn532 target timeout", ErrCardRemoved)
+	default:
+		return fmt.Errorf("pn532: InDataExchange status 0x%02X", code)
+	}
+}
+
+// encodePN532Frame wraps data (TFI included) in a normal or extended
+// information frame.
+func encodePN532Frame(data []byte) []byte {
+	n := len(data)
+	frame := []byte{0x00, 0x00, 0xFF}
+	if n < 0xFF {
+		frame = append(frame, byte(n), byte(-n))
+	} else {
+		frame = append(frame, 0xFF, 0xFF, byte(n>>8), byte(n), byte(-(n>>8 + n&0xFF)))
+	}
+	frame = append(frame, data...)
+	var sum byte
+	for _, b := range data {
+		sum += b
+	}
+	return append(frame, -sum, 0x00)
+}
+
+// readPN532Frame reads the next frame from r. It reports ack for ACK frames
+// and returns the data (TFI included) of information frames.
+func readPN532Frame(r *bufio.Reader) (data []byte, ack bool, err error) {
+	// Find the start code; preamble, postamble and wakeup bytes are skipped.
+	var prev byte = 0xFF
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, false, err
+		}
+		if prev == 0x00 && b == 0xFF {
+			break
+		}
+		prev = b
+	}
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, false, err
+	}
+	var n int
+	switch {
+	case hdr[0] == 0x00 && hdr[1] == 0xFF:
+		return nil, true, nil
+	case hdr[0] == 0xFF && hdr[1] == 0x00:
+		return nil, false, errors.New("pn532: nack")
+	case hdr[0] == 0xFF && hdr[1] == 0xFF:
+		var ext [3]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return nil, false, err
+		}
+		if ext[0]+ext[1]+ext[2] != 0 {
+			return nil, false, errors.New("pn532: bad extended length checksum")
+		}
+		n = int(ext[0])<<8 | int(ext[1])
+	default:
+		if hdr[0]+hdr[1] != 0 {
+			return nil, false, errors.New("pn532: bad length checksum")
+		}
+		n = int(hdr[0])
+	}
+	buf := make([]byte, n+1)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, false, err
+	}
+	var sum byte
+	for _, b := range buf {
+		sum += b
+	}
+	if sum != 0 {
+		return nil, false, errors.New("pn532: bad data checksum")
+	}
+	return buf[:n], false, nil
+}
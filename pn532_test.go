@@ -0,0 +1,150 @@
+//go:build linux
+
+package ezsignnfc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+// openPTY returns the master side of a new pseudo terminal and the path of
+// its slave.
+func openPTY(t *testing.T) (*os.File, string) {
+	t.Helper()
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("pty unavailable: %v", err)
+	}
+	fd := master.Fd()
+	var unlock int32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); errno != 0 {
+		master.Close()
+		t.Skipf("unlock pty: %v", errno)
+	}
+	var n uint32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); errno != 0 {
+		master.Close()
+		t.Skipf("get pty number: %v", errno)
+	}
+	return master, fmt.Sprintf("/dev/pts/%d", n)
+}
+
+// fakePN532 answers PN532 frames on the master side of a pty and forwards
+// InDataExchange payloads to a Simulator.
+type fakePN532 struct {
+	port    *os.File
+	sim     *Simulator
+	uid     []byte
+	present atomic.Bool
+}
+
+func (f *fakePN532) serve() {
+	r := bufio.NewReader(f.port)
+	for {
+		data, ack, err := readPN532Frame(r)
+		if err != nil {
+			return
+		}
+		if ack || len(data) < 2 || data[0] != pn532HostToPN532 {
+			continue
+		}
+		f.port.Write(pn532Ack)
+		resp := []byte{pn532PN532ToHost, data[1] + 1}
+		switch data[1] {
+		case pn532CmdInListPassiveTarget:
+			if !f.present.Load() {
+				resp = append(resp, 0x00)
+				break
+			}
+			resp = append(resp, 0x01, 0x01, 0x00, 0x44, 0x20, byte(len(f.uid)))
+			resp = append(resp, f.uid...)
+			resp = append(resp, 0x05, 0x78, 0x80, 0x70, 0x02)
+		case pn532CmdInDataExchange:
+			if !f.present.Load() {
+				resp = append(resp, 0x01)
+				break
+			}
+			out, _ := f.sim.Transmit(data[3:])
+			resp = append(append(resp, 0x00), out...)
+		}
+		f.port.Write(encodePN532Frame(resp))
+	}
+}
+
+func TestPN532TransportOverPTY(t *testing.T) {
+	master, slave := openPTY(t)
+	defer master.Close()
+
+	profile := PresetProfiles[Product29Mono]
+	fake := &fakePN532{
+		port: master,
+		sim:  NewSimulator(profile),
+		uid:  []byte{0x04, 0x10, 0x20, 0x30, 0x40, 0x50, 0x60},
+	}
+	fake.present.Store(true)
+	go fake.serve()
+
+	tr, err := OpenPN532(slave, 115200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev, _ := OpenTransport(profile, tr)
+	defer dev.Close()
+
+	uid, err := dev.UID(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(uid, fake.uid) {
+		t.Fatalf("uid: got %X want %X", uid, fake.uid)
+	}
+	// Full-size fragments need extended frames.
+	if err := dev.WritePixels(context.Background(), make([]uint8, profile.Width*profile.Height)); err != nil {
+		t.Fatal(err)
+	}
+	if fake.sim.Refreshes() != 1 {
+		t.Fatalf("refreshes: got %d want 1", fake.sim.Refreshes())
+	}
+
+	fake.present.Store(false)
+	_, err = tr.Transmit(apduPollStatus)
+	if !errors.Is(err, ErrCardRemoved) {
+		t.Fatalf("expected ErrCardRemoved, got %v", err)
+	}
+}
+
+func TestPN532FrameRoundTrip(t *testing.T) {
+	for _, n := range []int{1, 254, 255, 300} {
+		data := bytes.Repeat([]byte{0xA5}, n)
+		var buf bytes.Buffer
+		buf.Write(pn532Wakeup)
+		buf.Write(pn532Ack)
+		buf.Write(encodePN532Frame(data))
+
+		r := bufio.NewReader(&buf)
+		if _, ack, err := readPN532Frame(r); err != nil || !ack {
+			t.Fatalf("n=%d: expected ack, got ack=%v err=%v", n, ack, err)
+		}
+		got, ack, err := readPN532Frame(r)
+		if err != nil || ack {
+			t.Fatalf("n=%d: read frame: ack=%v err=%v", n, ack, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("n=%d: round trip mismatch", n)
+		}
+	}
+
+	bad := encodePN532Frame([]byte{pn532PN532ToHost, 0x41, 0x00})
+	bad[len(bad)-2]++
+	if _, _, err := readPN532Frame(bufio.NewReader(bytes.NewReader(bad))); err == nil {
+		t.Fatal("expected checksum error")
+	}
+}
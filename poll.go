@@ -0,0 +1,129 @@
+package ezsignnfc
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PollStrategy decides the pacing of refresh status polls.
+// NextDelay is called after each busy poll with the number of polls made so
+// far and the time elapsed since the refresh started. It returns the delay
+// before the next poll, or false to give up with ErrRefreshTimeout.
+type PollStrategy interface {
+	NextDelay(polls int, elapsed time.Duration) (time.Duration, bool)
+}
+
+// attemptLimiter is implemented by strategies with a fixed poll count,
+// which is then reported as Progress.Total.
+type attemptLimiter interface {
+	maxAttempts() int
+}
+
+type fixedPoll struct {
+	interval time.Duration
+	attempts int
+}
+
+type exponentialPoll struct {
+	initial  time.Duration
+	max      time.Duration
+	factor   float64
+	attempts int
+}
+
+type deadlinePoll struct {
+	interval time.Duration
+	timeout  time.Duration
+}
+
+// FixedPoll polls every interval, at most attempts times.
+func FixedPoll(interval time.Duration, attempts int) (PollStrategy, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("poll interval must be > 0")
+	}
+	if attempts <= 0 {
+		return nil, fmt.Errorf("poll attempts must be > 0")
+	}
+	return fixedPoll{interval: interval, attempts: attempts}, nil
+}
+
+// ExponentialPoll starts at initial and multiplies the delay by factor after
+// each poll, capped at max. attempts limits the poll count; zero means no limit.
+func ExponentialPoll(initial, max time.Duration, factor float64, attempts int) (PollStrategy, error) {
+	if initial <= 0 {
+		return nil, fmt.Errorf("initial poll interval must be > 0")
+	}
+	if max < initial {
+		return nil, fmt.Errorf("max poll interval must be >= initial")
+	}
+	if factor < 1 {
+		return nil, fmt.Errorf("backoff factor must be >= 1")
+	}
+	if attempts < 0 {
+		return nil, fmt.Errorf("poll attempts must be >= 0")
+	}
+	return exponentialPoll{initial: initial, max: max, factor: factor, attempts: attempts}, nil
+}
+
+// DeadlinePoll polls every interval until timeout has elapsed since the refresh started.
+func DeadlinePoll(interval, timeout time.Duration) (PollStrategy, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("poll interval must be > 0")
+	}
+	if timeout <= 0 {
+		return nil, fmt.Errorf("poll timeout must be > 0")
+	}
+	return deadlinePoll{interval: interval, timeout: timeout}, nil
+}
+
+func (p fixedPoll) NextDelay(polls int, elapsed time.Duration) (time.Duration, bool) {
+	if polls >= p.attempts {
+		return 0, false
+	}
+	return p.interval, true
+}
+
+func (p fixedPoll) maxAttempts() int {
+	return p.attempts
+}
+
+func (p exponentialPoll) NextDelay(polls int, elapsed time.Duration) (time.Duration, bool) {
+	if p.attempts > 0 && polls >= p.attempts {
+		return 0, false
+	}
+	delay := float64(p.initial)
+	for i := 1; i < polls && delay < float64(p.max); i++ {
+		delay *= p.factor
+	}
+	if delay > float64(p.max) {
+		return p.max, true
+	}
+	return time.Duration(delay), true
+}
+
+func (p exponentialPoll) maxAttempts() int {
+	return p.attempts
+}
+
+func (p deadlinePoll) NextDelay(polls int, elapsed time.Duration) (time.Duration, bool) {
+	remaining := p.timeout - elapsed
+	if remaining <= 0 {
+		return 0, false
+	}
+	if remaining < p.interval {
+		return remaining, true
+	}
+	return p.interval, true
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,94 @@
+package ezsignnfc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPollStrategies(t *testing.T) {
+	fixed, err := FixedPoll(100*time.Millisecond, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp, err := ExponentialPoll(10*time.Millisecond, 50*time.Millisecond, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline, err := DeadlinePoll(100*time.Millisecond, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		strategy PollStrategy
+		polls    int
+		elapsed  time.Duration
+		delay    time.Duration
+		ok       bool
+	}{
+		{"fixed-first", fixed, 1, 0, 100 * time.Millisecond, true},
+		{"fixed-exhausted", fixed, 3, 0, 0, false},
+		{"exp-first", exp, 1, 0, 10 * time.Millisecond, true},
+		{"exp-third", exp, 3, 0, 40 * time.Millisecond, true},
+		{"exp-capped", exp, 10, 0, 50 * time.Millisecond, true},
+		{"deadline-early", deadline, 1, 200 * time.Millisecond, 100 * time.Millisecond, true},
+		{"deadline-tail", deadline, 9, 950 * time.Millisecond, 50 * time.Millisecond, true},
+		{"deadline-expired", deadline, 10, time.Second, 0, false},
+	}
+	for _, tc := range tests {
+		delay, ok := tc.strategy.NextDelay(tc.polls, tc.elapsed)
+		if ok != tc.ok || delay != tc.delay {
+			t.Fatalf("%s: got (%s, %v) want (%s, %v)", tc.name, delay, ok, tc.delay, tc.ok)
+		}
+	}
+
+	if _, err := ExponentialPoll(time.Second, time.Millisecond, 2, 0); err == nil {
+		t.Fatal("expected error for max < initial")
+	}
+	if _, err := DeadlinePoll(time.Second, 0); err == nil {
+		t.Fatal("expected error for zero timeout")
+	}
+}
+
+func TestPollRefreshCancelDuringDelay(t *testing.T) {
+	profile := PresetProfiles[Product29Mono]
+	sim := NewSimulator(profile)
+	sim.SetRefreshLatency(time.Hour)
+	dev, _ := OpenTransport(profile, sim)
+	dev.SetPolling(time.Hour, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	dev.SetProgress(func(p Progress) {
+		if p.Phase == PhasePoll {
+			cancel()
+		}
+	})
+	start := time.Now()
+	err := dev.WritePixels(ctx, make([]uint8, profile.Width*profile.Height))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("cancellation was not observed during poll delay")
+	}
+}
+
+func TestLastRefreshDuration(t *testing.T) {
+	profile := PresetProfiles[Product29Mono]
+	sim := NewSimulator(profile)
+	sim.SetRefreshLatency(20 * time.Millisecond)
+	dev, _ := OpenTransport(profile, sim)
+	strategy, _ := DeadlinePoll(5*time.Millisecond, time.Second)
+	if err := dev.SetPollStrategy(strategy); err != nil {
+		t.Fatal(err)
+	}
+	if err := dev.WritePixels(context.Background(), make([]uint8, profile.Width*profile.Height)); err != nil {
+		t.Fatal(err)
+	}
+	if got := dev.LastRefreshDuration(); got < 20*time.Millisecond {
+		t.Fatalf("refresh duration: got %s want >= 20ms", got)
+	}
+}
@@ -0,0 +1,60 @@
+package ezsignnfc
+
+import (
+	"fmt"
+	"time"
+)
+
+// ProgressPhase identifies a step of a write.
+type ProgressPhase int
+
+const (
+	PhaseAuthenticate ProgressPhase = iota
+	PhaseSendAPDU
+	PhaseRefreshStarted
+	PhasePoll
+	PhaseDone
+)
+
+func (p ProgressPhase) String() string {
+	switch p {
+	case PhaseAuthenticate:
+		return "authenticate"
+	case PhaseSendAPDU:
+		return "send"
+	case PhaseRefreshStarted:
+		return "refresh-started"
+	case PhasePoll:
+		return "poll"
+	case PhaseDone:
+		return "done"
+	default:
+		return fmt.Sprintf("ProgressPhase(%d)", int(p))
+	}
+}
+
+// Progress is reported after each completed step of a write.
+// For PhaseSendAPDU, Index/Total count image APDUs (1-based); for PhasePoll
+// they count poll attempts against the configured maximum.
+type Progress struct {
+	Phase   ProgressPhase
+	Index   int
+	Total   int
+	Elapsed time.Duration
+}
+
+type progressReporter struct {
+	fn    func(Progress)
+	start time.Time
+}
+
+func newProgressReporter(fn func(Progress)) progressReporter {
+	return progressReporter{fn: fn, start: time.Now()}
+}
+
+func (r progressReporter) report(phase ProgressPhase, index, total int) {
+	if r.fn == nil {
+		return
+	}
+	r.fn(Progress{Phase: phase, Index: index, Total: total, Elapsed: time.Since(r.start)})
+}
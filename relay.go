@@ -0,0 +1,527 @@
+package ezsignnfc
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+// Relay protocol: every message is a type byte, a big-endian uint16 length
+// and the payload. The server greets with a version and a random nonce; the
+// client answers with HMAC-SHA256(token, nonce). After the server confirms
+// with the reader name, the client sends APDUs and gets raw responses
+// including SW. The token is never sent, but traffic is not encrypted: wrap
+// the listener and connection in TLS when the network is untrusted.
+//
+// A reconnect message asks the server to wait for a card on its reader again
+// after the client saw ErrCardRemoved; its payload is the longest wait in
+// milliseconds as a big-endian uint32, zero for no limit.
+const (
+	relayVersion = 1
+
+	relayMsgHello     = 'H' // version, nonce
+	relayMsgAuth      = 'A' // hmac
+	relayMsgOK        = 'O' // reader name
+	relayMsgTransmit  = 'T' // command apdu
+	relayMsgATR       = 'Q' // empty
+	relayMsgReconnect = 'C' // wait milliseconds
+	relayMsgResponse  = 'R' // response apdu or atr
+	relayMsgError     = 'E' // code, message
+
+	relayErrGeneric     = 0
+	relayErrCardRemoved = 1
+
+	relayNonceSize = 32
+
+	defaultRelayHandshakeTimeout = 10 * time.Second
+	defaultRelayIdleTimeout      = time.Minute
+
+	// relayReconnectWait bounds how long a new client waits for a card when
+	// the previous one left the reader without a card.
+	relayReconnectWait = 10 * time.Second
+
+	defaultRelayRoundTripTimeout = 30 * time.Second
+)
+
+// RelayServer exposes a Transport, typically a local PC/SC reader, to
+// RelayTransport clients over a stream listener. One client is served at a
+// time so that the APDU sequences of two writers never interleave; others
+// wait after authenticating. The server does not close the transport.
+//
+// When the transport implements Reconnector, the server reconnects after the
+// card was removed, either on request of the client or when the next client
+// is admitted, so a relay can serve one tag after another.
+type RelayServer struct {
+	transport Transport
+	token     []byte
+	logger    *slog.Logger
+	handshake time.Duration
+	idle      time.Duration
+
+	mu      sync.Mutex
+	removed bool
+}
+
+// NewRelayServer returns a server for transport that admits clients
+// presenting token.
+func NewRelayServer(transport Transport, token string) (*RelayServer, error) {
+	if transport == nil {
+		return nil, fmt.Errorf("transport must not be nil")
+	}
+	if token == "" {
+		return nil, fmt.Errorf("relay token must not be empty")
+	}
+	return &RelayServer{
+		transport: transport,
+		token:     []byte(token),
+		logger:    slog.New(discardHandler{}),
+		handshake: defaultRelayHandshakeTimeout,
+		idle:      defaultRelayIdleTimeout,
+	}, nil
+}
+
+// SetTimeouts bounds how long a client may take to authenticate and how
+// long an admitted client may stay silent before it is disconnected and the
+// reader is handed to the next client. Zero disables a limit. The defaults
+// are 10 seconds and one minute. Call it before Serve.
+func (s *RelayServer) SetTimeouts(handshake, idle time.Duration) error {
+	if handshake < 0 || idle < 0 {
+		return fmt.Errorf("relay timeouts must be >= 0")
+	}
+	s.handshake = handshake
+	s.idle = idle
+	return nil
+}
+
+// SetLogger sets the logger for client connections.
+func (s *RelayServer) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = slog.New(discardHandler{})
+	}
+	s.logger = logger
+}
+
+// Serve accepts clients on ln until ctx is done, then closes ln and every
+// client connection and returns nil.
+func (s *RelayServer) Serve(ctx context.Context, ln net.Listener) error {
+	stop := context.AfterFunc(ctx, func() { ln.Close() })
+	defer stop()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stopConn := context.AfterFunc(ctx, func() { conn.Close() })
+			defer stopConn()
+			defer conn.Close()
+			if err := s.serveConn(ctx, conn); err != nil && !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				s.logger.Warn("relay client failed", slog.String("remote", conn.RemoteAddr().String()), slog.Any("error", err))
+			}
+		}()
+	}
+}
+
+func (s *RelayServer) serveConn(ctx context.Context, conn net.Conn) error {
+	r := bufio.NewReader(conn)
+	nonce := make([]byte, relayNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	if s.handshake > 0 {
+		conn.SetDeadline(time.Now().Add(s.handshake))
+	}
+	if err := writeRelayMsg(conn, relayMsgHello, append([]byte{relayVersion}, nonce...)); err != nil {
+		return err
+	}
+	typ, payload, err := readRelayMsg(r)
+	if err != nil {
+		return err
+	}
+	if typ != relayMsgAuth || !hmac.Equal(payload, relayMAC(s.token, nonce)) {
+		writeRelayError(conn, relayErrGeneric, ErrRelayAuth.Error())
+		return fmt.Errorf("%w from %s", ErrRelayAuth, conn.RemoteAddr())
+	}
+	// Waiting for the reader is bounded by the client, not the handshake.
+	conn.SetDeadline(time.Time{})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	remote := conn.RemoteAddr().String()
+	s.logger.Info("relay client connected", slog.String("remote", remote))
+	defer s.logger.Info("relay client disconnected", slog.String("remote", remote))
+	if s.removed {
+		rctx, cancel := context.WithTimeout(ctx, relayReconnectWait)
+		err := s.reconnect(rctx)
+		cancel()
+		if err != nil {
+			s.logger.Warn("relay reconnect failed", slog.Any("error", err))
+		}
+	}
+	s.setIdleDeadline(conn)
+	if err := writeRelayMsg(conn, relayMsgOK, []byte(s.transport.ReaderName())); err != nil {
+		return err
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil
+		}
+		s.setIdleDeadline(conn)
+		typ, payload, err := readRelayMsg(r)
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case relayMsgTransmit:
+			resp, err := s.transport.Transmit(payload)
+			s.noteRemoved(err)
+			if err != nil {
+				err = writeRelayError(conn, relayErrorCode(err), err.Error())
+			} else {
+				err = writeRelayMsg(conn, relayMsgResponse, resp)
+			}
+			if err != nil {
+				return err
+			}
+		case relayMsgATR:
+			atr, err := transportATR(s.transport)
+			s.noteRemoved(err)
+			if err != nil {
+				err = writeRelayError(conn, relayErrorCode(err), err.Error())
+			} else {
+				err = writeRelayMsg(conn, relayMsgResponse, atr)
+			}
+			if err != nil {
+				return err
+			}
+		case relayMsgReconnect:
+			err := s.clientReconnect(ctx, conn, r, payload)
+			s.setIdleDeadline(conn)
+			if err != nil {
+				err = writeRelayError(conn, relayErrorCode(err), err.Error())
+			} else {
+				err = writeRelayMsg(conn, relayMsgResponse, nil)
+			}
+			if err != nil {
+				return err
+			}
+		default:
+			writeRelayError(conn, relayErrGeneric, fmt.Sprintf("unexpected message %q", typ))
+			return fmt.Errorf("unexpected relay message %q", typ)
+		}
+	}
+}
+
+// clientReconnect serves a reconnect message. The wait ends early when the
+// client disconnects.
+func (s *RelayServer) clientReconnect(ctx context.Context, conn net.Conn, r *bufio.Reader, payload []byte) error {
+	if len(payload) != 4 {
+		return fmt.Errorf("malformed reconnect message")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if ms := binary.BigEndian.Uint32(payload); ms > 0 {
+		var stop context.CancelFunc
+		ctx, stop = context.WithTimeout(ctx, time.Duration(ms)*time.Millisecond)
+		defer stop()
+	}
+	// The client sends nothing while it waits, so a read only returns once
+	// it is gone.
+	conn.SetReadDeadline(time.Time{})
+	gone := make(chan struct{})
+	go func() {
+		if _, err := r.Peek(1); err != nil {
+			cancel()
+		}
+		close(gone)
+	}()
+	err := s.reconnect(ctx)
+	conn.SetReadDeadline(time.Unix(1, 0))
+	<-gone
+	conn.SetReadDeadline(time.Time{})
+	return err
+}
+
+// reconnect waits for a card on the server's reader. s.mu must be held.
+func (s *RelayServer) reconnect(ctx context.Context) error {
+	rec, ok := s.transport.(Reconnector)
+	if !ok {
+		return fmt.Errorf("relay: reader cannot reconnect")
+	}
+	if err := rec.Reconnect(ctx); err != nil {
+		return fmt.Errorf("relay reconnect: %w", err)
+	}
+	s.removed = false
+	s.logger.Info("relay reconnected", slog.String("reader", s.transport.ReaderName()))
+	return nil
+}
+
+// noteRemoved remembers that the card left the reader. s.mu must be held.
+func (s *RelayServer) noteRemoved(err error) {
+	if errors.Is(err, ErrCardRemoved) {
+		s.removed = true
+	}
+}
+
+func (s *RelayServer) setIdleDeadline(conn net.Conn) {
+	if s.idle > 0 {
+		conn.SetDeadline(time.Now().Add(s.idle))
+	}
+}
+
+// RelayTransport is a Transport that sends APDUs to a RelayServer. Each
+// request must be answered within a timeout (30s by default, see
+// SetTimeout), so a partitioned network or a stalled server fails the call
+// instead of blocking it.
+type RelayTransport struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	reader  string
+	timeout time.Duration
+	closed  bool
+	// broken is the I/O error that left the stream out of sync; later calls
+	// fail with it.
+	broken error
+}
+
+// DialRelay connects to the relay server at addr over TCP and authenticates
+// with token. ctx bounds the dial and the handshake, including the wait
+// while another client holds the reader.
+func DialRelay(ctx context.Context, addr, token string) (*RelayTransport, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("dial relay %s: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) })
+	t, err := NewRelayTransport(conn, token)
+	if !stop() && err == nil {
+		err = ctx.Err()
+		t = nil
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return t, nil
+}
+
+// NewRelayTransport authenticates with token on an established connection,
+// e.g. a TLS connection. The transport owns conn only when it is returned
+// without error.
+func NewRelayTransport(conn net.Conn, token string) (*RelayTransport, error) {
+	r := bufio.NewReader(conn)
+	typ, payload, err := readRelayMsg(r)
+	if err != nil {
+		return nil, fmt.Errorf("relay hello: %w", err)
+	}
+	if typ != relayMsgHello || len(payload) != 1+relayNonceSize {
+		return nil, fmt.Errorf("relay hello: unexpected message %q", typ)
+	}
+	if payload[0] != relayVersion {
+		return nil, fmt.Errorf("relay hello: unsupported version %d", payload[0])
+	}
+	if err := writeRelayMsg(conn, relayMsgAuth, relayMAC([]byte(token), payload[1:])); err != nil {
+		return nil, err
+	}
+	typ, payload, err = readRelayMsg(r)
+	if err != nil {
+		return nil, fmt.Errorf("relay auth: %w", err)
+	}
+	switch typ {
+	case relayMsgOK:
+		return &RelayTransport{conn: conn, r: r, reader: string(payload), timeout: defaultRelayRoundTripTimeout}, nil
+	case relayMsgError:
+		return nil, ErrRelayAuth
+	default:
+		return nil, fmt.Errorf("relay auth: unexpected message %q", typ)
+	}
+}
+
+// SetTimeout bounds each request to the server, from sending it to reading
+// the response. Zero disables the limit. Reconnect waits are bounded by
+// their ctx instead.
+func (t *RelayTransport) SetTimeout(d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("relay timeout must be >= 0")
+	}
+	t.timeout = d
+	return nil
+}
+
+func (t *RelayTransport) Transmit(apdu []byte) ([]byte, error) {
+	return t.roundTrip(relayMsgTransmit, apdu, t.deadline())
+}
+
+// Abort implements the transmit watchdog's abort by expiring the connection
+// deadline. The interrupted exchange leaves the stream out of sync, so the
+// transport cannot be used afterwards.
+func (t *RelayTransport) Abort() error {
+	return t.conn.SetDeadline(time.Now())
+}
+
+// Reconnect implements Reconnector: the server waits until a card is placed
+// on its reader again. When ctx is done first the transport is closed,
+// because the server may still answer later.
+func (t *RelayTransport) Reconnect(ctx context.Context) error {
+	if t.closed {
+		return fmt.Errorf("transport closed")
+	}
+	var wait [4]byte
+	var deadline time.Time
+	if d, ok := ctx.Deadline(); ok {
+		ms := time.Until(d).Milliseconds()
+		binary.BigEndian.PutUint32(wait[:], uint32(min(max(ms, 1), math.MaxUint32)))
+		if t.timeout > 0 {
+			deadline = d.Add(t.timeout)
+		}
+	}
+	conn := t.conn
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) })
+	_, err := t.roundTrip(relayMsgReconnect, wait[:], deadline)
+	if !stop() {
+		t.Close()
+		return context.Cause(ctx)
+	}
+	return err
+}
+
+// ATR implements ATRProvider with the ATR of the card on the server.
+func (t *RelayTransport) ATR() ([]byte, error) {
+	atr, err := t.roundTrip(relayMsgATR, nil, t.deadline())
+	if err != nil {
+		return nil, err
+	}
+	if len(atr) == 0 {
+		return nil, nil
+	}
+	return atr, nil
+}
+
+func (t *RelayTransport) Close() error {
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+	if t.broken != nil {
+		// roundTrip already closed the connection.
+		return nil
+	}
+	return t.conn.Close()
+}
+
+// ReaderName returns the name of the reader on the server.
+func (t *RelayTransport) ReaderName() string {
+	return t.reader
+}
+
+// deadline returns the deadline for a request sent now.
+func (t *RelayTransport) deadline() time.Time {
+	if t.timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(t.timeout)
+}
+
+// roundTrip sends one request and reads its response before deadline; the
+// zero time means no deadline.
+func (t *RelayTransport) roundTrip(typ byte, payload []byte, deadline time.Time) ([]byte, error) {
+	if t.closed {
+		return nil, fmt.Errorf("transport closed")
+	}
+	if t.broken != nil {
+		return nil, fmt.Errorf("relay connection unusable: %w", t.broken)
+	}
+	t.conn.SetDeadline(deadline)
+	if err := writeRelayMsg(t.conn, typ, payload); err != nil {
+		return nil, t.fail(err)
+	}
+	rtyp, resp, err := readRelayMsg(t.r)
+	if err != nil {
+		return nil, t.fail(err)
+	}
+	switch rtyp {
+	case relayMsgResponse:
+		return resp, nil
+	case relayMsgError:
+		if len(resp) > 0 && resp[0] == relayErrCardRemoved {
+			return nil, fmt.Errorf("%w: relay: %s", ErrCardRemoved, resp[1:])
+		}
+		if len(resp) > 0 {
+			resp = resp[1:]
+		}
+		return nil, fmt.Errorf("relay: %s", resp)
+	default:
+		return nil, fmt.Errorf("relay: unexpected message %q", rtyp)
+	}
+}
+
+// fail closes the connection after an I/O error, which may have left a
+// partial message on the stream.
+func (t *RelayTransport) fail(err error) error {
+	t.broken = err
+	t.conn.Close()
+	return err
+}
+
+func relayMAC(token, nonce []byte) []byte {
+	m := hmac.New(sha256.New, token)
+	m.Write(nonce)
+	return m.Sum(nil)
+}
+
+func relayErrorCode(err error) byte {
+	if errors.Is(err, ErrCardRemoved) {
+		return relayErrCardRemoved
+	}
+	return relayErrGeneric
+}
+
+func writeRelayError(w io.Writer, code byte, msg string) error {
+	return writeRelayMsg(w, relayMsgError, append([]byte{code}, msg...))
+}
+
+func writeRelayMsg(w io.Writer, typ byte, payload []byte) error {
+	if len(payload) > 0xFFFF {
+		return fmt.Errorf("relay message too large: %d", len(payload))
+	}
+	buf := make([]byte, 3, 3+len(payload))
+	buf[0] = typ
+	binary.BigEndian.PutUint16(buf[1:], uint16(len(payload)))
+	_, err := w.Write(append(buf, payload...))
+	return err
+}
+
+func readRelayMsg(r *bufio.Reader) (byte, []byte, error) {
+	var hdr [3]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(hdr[1:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return hdr[0], payload, nil
+}
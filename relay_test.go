@@ -0,0 +1,335 @@
+package ezsignnfc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func startRelay(t *testing.T, transport Transport, token string, setup ...func(*RelayServer)) string {
+	t.Helper()
+	srv, err := NewRelayServer(transport, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fn := range setup {
+		fn(srv)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, ln) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("serve: %v", err)
+		}
+	})
+	return ln.Addr().String()
+}
+
+func TestRelayWritePixels(t *testing.T) {
+	profile := PresetProfiles[Product29Mono]
+	sim := NewSimulator(profile)
+	sim.SetIdentity([]byte{0x04, 0x01, 0x02, 0x03}, []byte{0x3B, 0x8F, 0x80, 0x01})
+	addr := startRelay(t, sim, "s3cret")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tr, err := DialRelay(ctx, addr, "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev, _ := OpenTransport(profile, tr)
+	defer dev.Close()
+
+	if dev.ReaderName() != sim.ReaderName() {
+		t.Fatalf("reader name: got %q want %q", dev.ReaderName(), sim.ReaderName())
+	}
+	atr, err := dev.ATR()
+	if err != nil || !bytes.Equal(atr, []byte{0x3B, 0x8F, 0x80, 0x01}) {
+		t.Fatalf("atr: got %X, %v", atr, err)
+	}
+	pixels := make([]uint8, profile.Width*profile.Height)
+	pixels[0] = ColorWhite
+	if err := dev.WritePixels(ctx, pixels); err != nil {
+		t.Fatal(err)
+	}
+	if sim.Refreshes() != 1 || sim.Pixels()[0] != ColorWhite {
+		t.Fatalf("write did not reach the simulator: refreshes=%d", sim.Refreshes())
+	}
+}
+
+func TestRelayRejectsWrongToken(t *testing.T) {
+	addr := startRelay(t, NewSimulator(PresetProfiles[Product29Mono]), "s3cret")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := DialRelay(ctx, addr, "guess"); !errors.Is(err, ErrRelayAuth) {
+		t.Fatalf("expected ErrRelayAuth, got %v", err)
+	}
+	if _, err := NewRelayServer(NewSimulator(PresetProfiles[Product29Mono]), ""); err == nil {
+		t.Fatal("expected error for empty token")
+	}
+}
+
+func TestRelayCardRemoved(t *testing.T) {
+	removed := funcTransport(func([]byte) ([]byte, error) {
+		return nil, ErrCardRemoved
+	})
+	addr := startRelay(t, removed, "s3cret")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tr, err := DialRelay(ctx, addr, "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+	if _, err := tr.Transmit(apduPollStatus); !errors.Is(err, ErrCardRemoved) {
+		t.Fatalf("expected ErrCardRemoved, got %v", err)
+	}
+}
+
+func TestRelayTimeouts(t *testing.T) {
+	sim := NewSimulator(PresetProfiles[Product29Mono])
+	addr := startRelay(t, sim, "s3cret", func(s *RelayServer) {
+		if err := s.SetTimeouts(100*time.Millisecond, 100*time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.Run("handshake", func(t *testing.T) {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(2 * time.Second))
+		// Read the hello and never answer it.
+		if _, _, err := readRelayMsg(bufio.NewReader(conn)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+			t.Fatalf("expected the server to drop a silent client, got %v", err)
+		}
+	})
+
+	t.Run("idle", func(t *testing.T) {
+		idle, err := DialRelay(ctx, addr, "s3cret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer idle.Close()
+		// The idle client holds the reader until its deadline expires.
+		next, err := DialRelay(ctx, addr, "s3cret")
+		if err != nil {
+			t.Fatalf("second client not admitted: %v", err)
+		}
+		defer next.Close()
+		if _, err := next.Transmit(apduGetUID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idle.Transmit(apduGetUID); err == nil {
+			t.Fatal("expected the idle client to be disconnected")
+		}
+	})
+
+	srv, _ := NewRelayServer(sim, "s3cret")
+	if err := srv.SetTimeouts(-time.Second, 0); err == nil {
+		t.Fatal("expected error for negative timeout")
+	}
+}
+
+// pulledTransport loses the card at the removeAt-th exchange and keeps
+// failing until Reconnect.
+type pulledTransport struct {
+	*Simulator
+	mu         sync.Mutex
+	removeAt   int
+	count      int
+	removed    bool
+	reconnects int
+}
+
+func (p *pulledTransport) Transmit(apdu []byte) ([]byte, error) {
+	p.mu.Lock()
+	p.count++
+	if p.count == p.removeAt {
+		p.removed = true
+	}
+	removed := p.removed
+	p.mu.Unlock()
+	if removed {
+		return nil, ErrCardRemoved
+	}
+	return p.Simulator.Transmit(apdu)
+}
+
+func (p *pulledTransport) Reconnect(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.removed = false
+	p.reconnects++
+	return nil
+}
+
+func (p *pulledTransport) Reconnects() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.reconnects
+}
+
+func TestRelayReconnect(t *testing.T) {
+	profile := PresetProfiles[Product29Mono]
+	pixels := make([]uint8, profile.Width*profile.Height)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.Run("client-request", func(t *testing.T) {
+		sim := NewSimulator(profile)
+		// GET DATA and VERIFY pass, then the card leaves on the first image fragment.
+		pulled := &pulledTransport{Simulator: sim, removeAt: 3}
+		addr := startRelay(t, pulled, "s3cret")
+		tr, err := DialRelay(ctx, addr, "s3cret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		dev, _ := OpenTransport(profile, tr)
+		defer dev.Close()
+		dev.SetRetryBudget(1)
+		dev.SetAutoReconnect(true)
+		if err := dev.WritePixels(ctx, pixels); err != nil {
+			t.Fatalf("WritePixels: %v", err)
+		}
+		if sim.Refreshes() != 1 || pulled.Reconnects() != 1 {
+			t.Fatalf("refreshes %d, reconnects %d", sim.Refreshes(), pulled.Reconnects())
+		}
+	})
+
+	t.Run("next-client", func(t *testing.T) {
+		sim := NewSimulator(profile)
+		pulled := &pulledTransport{Simulator: sim, removeAt: 1}
+		addr := startRelay(t, pulled, "s3cret")
+		first, err := DialRelay(ctx, addr, "s3cret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := first.Transmit(apduGetUID); !errors.Is(err, ErrCardRemoved) {
+			t.Fatalf("expected ErrCardRemoved, got %v", err)
+		}
+		first.Close()
+
+		// The server reconnects before admitting the next client.
+		second, err := DialRelay(ctx, addr, "s3cret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		dev, _ := OpenTransport(profile, second)
+		defer dev.Close()
+		if err := dev.WritePixels(ctx, pixels); err != nil {
+			t.Fatalf("WritePixels after tag swap: %v", err)
+		}
+		if pulled.Reconnects() != 1 {
+			t.Fatalf("reconnects: got %d want 1", pulled.Reconnects())
+		}
+	})
+}
+
+// startStalledRelay accepts clients, completes the handshake and then never
+// answers. The returned channel receives once per client that disconnects.
+func startStalledRelay(t *testing.T) (string, <-chan struct{}) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	gone := make(chan struct{}, 4)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				hello := append([]byte{relayVersion}, make([]byte, relayNonceSize)...)
+				writeRelayMsg(conn, relayMsgHello, hello)
+				readRelayMsg(r)
+				writeRelayMsg(conn, relayMsgOK, []byte("Stalled Reader"))
+				for {
+					if _, _, err := readRelayMsg(r); err != nil {
+						gone <- struct{}{}
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String(), gone
+}
+
+func TestRelayStalledServer(t *testing.T) {
+	addr, gone := startStalledRelay(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	expectGone := func(t *testing.T) {
+		t.Helper()
+		select {
+		case <-gone:
+		case <-time.After(2 * time.Second):
+			t.Fatal("connection to the stalled server was not closed")
+		}
+	}
+
+	t.Run("round-trip-timeout", func(t *testing.T) {
+		tr, err := DialRelay(ctx, addr, "s3cret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tr.Close()
+		if err := tr.SetTimeout(50 * time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+		var ne net.Error
+		if _, err := tr.Transmit(apduGetUID); !errors.As(err, &ne) || !ne.Timeout() {
+			t.Fatalf("expected a network timeout, got %v", err)
+		}
+		expectGone(t)
+		if _, err := tr.Transmit(apduGetUID); err == nil {
+			t.Fatal("expected the broken transport to stay unusable")
+		}
+		if err := tr.SetTimeout(-time.Second); err == nil {
+			t.Fatal("expected error for negative timeout")
+		}
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		tr, err := DialRelay(ctx, addr, "s3cret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr.SetTimeout(0)
+		profile := PresetProfiles[Product29Mono]
+		dev, _ := OpenTransport(profile, tr, WithTimeouts(20*time.Millisecond, 0))
+		defer dev.Close()
+		// Abort makes the transmit return, so the device does not need to
+		// abandon the transport.
+		err = dev.WritePixels(ctx, make([]uint8, profile.Width*profile.Height))
+		if !errors.Is(err, ErrTimeout) || errors.Is(err, ErrClosed) {
+			t.Fatalf("expected a timeout without ErrClosed, got %v", err)
+		}
+		expectGone(t)
+	})
+}
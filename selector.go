@@ -0,0 +1,197 @@
+package ezsignnfc
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ebfe/scard"
+)
+
+// readerState is a reader as seen by selectors. ATR is set only while a
+// card is present.
+type readerState struct {
+	Name    string
+	Present bool
+	ATR     []byte
+	flags   scard.StateFlag
+}
+
+type readerContainsSelector string
+type readerRegexpSelector struct{ re *regexp.Regexp }
+type readerATRSelector struct{ pattern atrPattern }
+type readerWithCardSelector struct{}
+
+// ReaderNameContains selects the first reader whose name contains sub,
+// ignoring case. Useful for names like "SONY FeliCa Port/PaSoRi 4.0 00 00"
+// whose suffix differs between machines.
+func ReaderNameContains(sub string) ReaderSelector {
+	return readerContainsSelector(sub)
+}
+
+// ReaderNameRegexp selects the first reader whose name matches re.
+func ReaderNameRegexp(re *regexp.Regexp) ReaderSelector {
+	return readerRegexpSelector{re: re}
+}
+
+// ReaderATR selects the first reader holding a card whose ATR matches
+// pattern. The pattern is hex; '?' matches any nibble, spaces and colons are
+// ignored, and a trailing '*' allows any remaining bytes.
+func ReaderATR(pattern string) (ReaderSelector, error) {
+	p, err := parseATRPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return readerATRSelector{pattern: p}, nil
+}
+
+// ReaderWithCard selects the first reader that currently has a card present.
+func ReaderWithCard() ReaderSelector {
+	return readerWithCardSelector{}
+}
+
+// ParseReaderSelector parses the selector syntax used by the CLI:
+//
+//	index:N        ReaderIndex(N)
+//	name:NAME      ReaderName(NAME)
+//	contains:SUB   ReaderNameContains(SUB)
+//	regex:RE       ReaderNameRegexp(RE)
+//	atr:PATTERN    ReaderATR(PATTERN)
+//	card           ReaderWithCard()
+//
+// Anything else is taken as an exact reader name.
+func ParseReaderSelector(spec string) (ReaderSelector, error) {
+	if spec == "" {
+		return nil, fmt.Errorf("reader selector must not be empty")
+	}
+	if spec == "card" {
+		return ReaderWithCard(), nil
+	}
+	kind, arg, ok := strings.Cut(spec, ":")
+	if !ok {
+		return ReaderName(spec), nil
+	}
+	switch kind {
+	case "index":
+		idx, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reader index %q: %w", arg, err)
+		}
+		return ReaderIndex(idx), nil
+	case "name":
+		return ReaderName(arg), nil
+	case "contains":
+		if arg == "" {
+			return nil, fmt.Errorf("reader substring must not be empty")
+		}
+		return ReaderNameContains(arg), nil
+	case "regex":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reader regex: %w", err)
+		}
+		return ReaderNameRegexp(re), nil
+	case "atr":
+		return ReaderATR(arg)
+	default:
+		return ReaderName(spec), nil
+	}
+}
+
+func (s readerContainsSelector) selectReader(readers []readerState) (string, error) {
+	sub := strings.ToLower(string(s))
+	if sub == "" {
+		return "", fmt.Errorf("reader substring must not be empty")
+	}
+	for _, r := range readers {
+		if strings.Contains(strings.ToLower(r.Name), sub) {
+			return r.Name, nil
+		}
+	}
+	return "", fmt.Errorf("no reader name contains %q", string(s))
+}
+
+func (s readerRegexpSelector) selectReader(readers []readerState) (string, error) {
+	if s.re == nil {
+		return "", fmt.Errorf("reader regexp must not be nil")
+	}
+	for _, r := range readers {
+		if s.re.MatchString(r.Name) {
+			return r.Name, nil
+		}
+	}
+	return "", fmt.Errorf("no reader name matches %q", s.re.String())
+}
+
+func (s readerATRSelector) selectReader(readers []readerState) (string, error) {
+	for _, r := range readers {
+		if r.Present && s.pattern.match(r.ATR) {
+			return r.Name, nil
+		}
+	}
+	return "", fmt.Errorf("no card with atr matching %s", s.pattern)
+}
+
+func (readerWithCardSelector) selectReader(readers []readerState) (string, error) {
+	for _, r := range readers {
+		if r.Present {
+			return r.Name, nil
+		}
+	}
+	return "", fmt.Errorf("no reader has a card present")
+}
+
+// atrPattern holds value and mask per nibble-wildcarded byte.
+type atrPattern struct {
+	value  []byte
+	mask   []byte
+	prefix bool
+	src    string
+}
+
+func parseATRPattern(s string) (atrPattern, error) {
+	p := atrPattern{src: s}
+	s = strings.NewReplacer(" ", "", ":", "").Replace(s)
+	if strings.HasSuffix(s, "*") {
+		p.prefix = true
+		s = s[:len(s)-1]
+	}
+	if s == "" || len(s)%2 != 0 {
+		return atrPattern{}, fmt.Errorf("invalid atr pattern %q: need whole bytes", p.src)
+	}
+	for i := 0; i < len(s); i += 2 {
+		var v, m byte
+		for _, c := range s[i : i+2] {
+			v, m = v<<4, m<<4
+			if c == '?' {
+				continue
+			}
+			n, err := strconv.ParseUint(string(c), 16, 8)
+			if err != nil {
+				return atrPattern{}, fmt.Errorf("invalid atr pattern %q: bad digit %q", p.src, c)
+			}
+			v |= byte(n)
+			m |= 0x0F
+		}
+		p.value = append(p.value, v)
+		p.mask = append(p.mask, m)
+	}
+	return p, nil
+}
+
+func (p atrPattern) match(atr []byte) bool {
+	if len(atr) < len(p.value) || (!p.prefix && len(atr) != len(p.value)) {
+		return false
+	}
+	for i := range p.value {
+		if atr[i]&p.mask[i] != p.value[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (p atrPattern) String() string {
+	return p.src
+}
@@ -0,0 +1,92 @@
+package ezsignnfc
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestReaderSelectors(t *testing.T) {
+	readers := []readerState{
+		{Name: "ACS ACR122U PICC Interface 00 00"},
+		{Name: "SONY FeliCa Port/PaSoRi 4.0 00 00", Present: true, ATR: []byte{0x3B, 0x8F, 0x80, 0x01, 0x80}},
+		{Name: "SONY FeliCa Port/PaSoRi 4.0 01 00", Present: true, ATR: []byte{0x3B, 0x8A, 0x80, 0x01}},
+	}
+	mustATR := func(p string) ReaderSelector {
+		s, err := ReaderATR(p)
+		if err != nil {
+			t.Fatalf("ReaderATR(%q): %v", p, err)
+		}
+		return s
+	}
+
+	tests := []struct {
+		name string
+		sel  ReaderSelector
+		want string
+	}{
+		{name: "contains", sel: ReaderNameContains("pasori"), want: readers[1].Name},
+		{name: "regexp", sel: ReaderNameRegexp(regexp.MustCompile(`PaSoRi .* 01`)), want: readers[2].Name},
+		{name: "with-card", sel: ReaderWithCard(), want: readers[1].Name},
+		{name: "atr-exact", sel: mustATR("3B 8A 80 01"), want: readers[2].Name},
+		{name: "atr-wildcard", sel: mustATR("3B8?8001*"), want: readers[1].Name},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := resolveReaderState(readers, []ReaderSelector{tc.sel})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("got %q want %q", got, tc.want)
+			}
+		})
+	}
+
+	misses := []ReaderSelector{
+		ReaderNameContains("omnikey"),
+		ReaderNameRegexp(regexp.MustCompile(`^PaSoRi`)),
+		mustATR("3B8F8001"),
+	}
+	for _, sel := range misses {
+		if _, err := resolveReaderState(readers, []ReaderSelector{sel}); err == nil {
+			t.Fatalf("expected no match for %#v", sel)
+		}
+	}
+	if _, err := resolveReaderState(readers[:1], []ReaderSelector{ReaderWithCard()}); err == nil {
+		t.Fatal("expected error when no reader has a card")
+	}
+}
+
+func TestParseReaderSelector(t *testing.T) {
+	tests := []struct {
+		spec string
+		want ReaderSelector
+	}{
+		{spec: "ACS ACR122U PICC Interface 00 00", want: ReaderName("ACS ACR122U PICC Interface 00 00")},
+		{spec: "name:index:1", want: ReaderName("index:1")},
+		{spec: "index:1", want: ReaderIndex(1)},
+		{spec: "contains:PaSoRi", want: ReaderNameContains("PaSoRi")},
+		{spec: "card", want: ReaderWithCard()},
+		{spec: "Vendor:Reader", want: ReaderName("Vendor:Reader")},
+	}
+	for _, tc := range tests {
+		got, err := ParseReaderSelector(tc.spec)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.spec, err)
+		}
+		if got != tc.want {
+			t.Fatalf("%q: got %#v want %#v", tc.spec, got, tc.want)
+		}
+	}
+	if sel, err := ParseReaderSelector("regex:PaSoRi.*00$"); err != nil {
+		t.Fatal(err)
+	} else if _, ok := sel.(readerRegexpSelector); !ok {
+		t.Fatalf("regex: got %#v", sel)
+	}
+
+	for _, spec := range []string{"", "index:x", "contains:", "regex:(", "atr:3B8", "atr:3BZZ"} {
+		if _, err := ParseReaderSelector(spec); err == nil {
+			t.Fatalf("%q: expected error", spec)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package ezsignnfc
+
+import (
+	"fmt"
+	"os"
+)
+
+func openSerialPort(path string, baud int) (*os.File, error) {
+	return nil, fmt.Errorf("serial ports are only supported on linux")
+}
@@ -0,0 +1,116 @@
+package ezsignnfc
+
+import (
+	"context"
+	"fmt"
+	"image"
+)
+
+// transactor is implemented by transports that can lock the card against
+// other processes, like PC/SC transactions.
+type transactor interface {
+	BeginTransaction() error
+	EndTransaction() error
+}
+
+// Session holds exclusive use of a Device across several commands.
+// It authenticates once when it begins; other callers of the device block
+// until Release. A Session must be used from a single goroutine.
+type Session struct {
+	d        *Device
+	released bool
+}
+
+// BeginSession locks the device and authenticates.
+func (d *Device) BeginSession(ctx context.Context) (*Session, error) {
+	d.mu.Lock()
+	if d.transport == nil {
+		d.mu.Unlock()
+		return nil, ErrClosed
+	}
+	tx, hasTx := d.transport.(transactor)
+	if hasTx {
+		if err := tx.BeginTransaction(); err != nil {
+			d.mu.Unlock()
+			return nil, fmt.Errorf("begin transaction: %w", err)
+		}
+	}
+	if err := d.bootstrap(ctx); err != nil {
+		if hasTx {
+			tx.EndTransaction()
+		}
+		d.mu.Unlock()
+		return nil, err
+	}
+	return &Session{d: d}, nil
+}
+
+// WriteImage quantizes img and writes it without authenticating again.
+func (s *Session) WriteImage(ctx context.Context, img image.Image) error {
+	return s.WriteImageWithOptions(ctx, img, ImageEncodeOptions{})
+}
+
+// WriteImageWithOptions quantizes img with opts and writes it.
+func (s *Session) WriteImageWithOptions(ctx context.Context, img image.Image, opts ImageEncodeOptions) error {
+	if err := s.check(); err != nil {
+		return err
+	}
+	apdus, err := EncodeImageToAPDUsWithOptions(s.d.profile, img, s.d.maxFragment, opts)
+	if err != nil {
+		return err
+	}
+	return s.d.writeAPDUs(ctx, apdus, false)
+}
+
+// WritePixels writes indexed pixels without authenticating again.
+func (s *Session) WritePixels(ctx context.Context, pixels []uint8) error {
+	if err := s.check(); err != nil {
+		return err
+	}
+	apdus, err := EncodePixelsToAPDUs(s.d.profile, pixels, s.d.maxFragment)
+	if err != nil {
+		return err
+	}
+	return s.d.writeAPDUs(ctx, apdus, false)
+}
+
+// Transmit sends a raw command APDU within the session.
+func (s *Session) Transmit(ctx context.Context, apdu []byte) ([]byte, uint16, error) {
+	if err := s.check(); err != nil {
+		return nil, 0, err
+	}
+	return s.d.transmitRaw(ctx, apdu)
+}
+
+// UID returns the tag UID.
+func (s *Session) UID(ctx context.Context) ([]byte, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	return s.d.uidLocked(ctx)
+}
+
+// Release ends the session and unlocks the device. It is safe to call twice.
+func (s *Session) Release() error {
+	if s.released {
+		return nil
+	}
+	s.released = true
+	defer s.d.mu.Unlock()
+	if tx, ok := s.d.transport.(transactor); ok {
+		if err := tx.EndTransaction(); err != nil {
+			return fmt.Errorf("end transaction: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *Session) check() error {
+	if s.released {
+		return fmt.Errorf("session released")
+	}
+	if s.d.transport == nil {
+		return ErrClosed
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package ezsignnfc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingTransport counts VERIFY commands sent to a Simulator.
+type countingTransport struct {
+	*Simulator
+	mu       sync.Mutex
+	verify   int
+	inFlight int
+	overlap  bool
+}
+
+func (c *countingTransport) Transmit(apdu []byte) ([]byte, error) {
+	c.mu.Lock()
+	c.inFlight++
+	if c.inFlight > 1 {
+		c.overlap = true
+	}
+	if bytes.Equal(apdu[:2], []byte{0x00, 0x20}) {
+		c.verify++
+	}
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.inFlight--
+		c.mu.Unlock()
+	}()
+	return c.Simulator.Transmit(apdu)
+}
+
+func TestDeviceConcurrentWrites(t *testing.T) {
+	profile := PresetProfiles[Product29Mono]
+	tr := &countingTransport{Simulator: NewSimulator(profile)}
+	dev, _ := OpenTransport(profile, tr)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			pixels := make([]uint8, profile.Width*profile.Height)
+			pixels[i] = ColorWhite
+			errs <- dev.WritePixels(context.Background(), pixels)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tr.overlap {
+		t.Fatal("apdus of concurrent writes interleaved")
+	}
+	if tr.Refreshes() != 4 {
+		t.Fatalf("refreshes: got %d want 4", tr.Refreshes())
+	}
+}
+
+func TestSession(t *testing.T) {
+	profile := PresetProfiles[Product29Mono]
+	tr := &countingTransport{Simulator: NewSimulator(profile)}
+	dev, _ := OpenTransport(profile, tr)
+
+	sess, err := dev.BeginSession(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pixels := make([]uint8, profile.Width*profile.Height)
+	for i := 0; i < 2; i++ {
+		if err := sess.WritePixels(context.Background(), pixels); err != nil {
+			t.Fatalf("session write %d: %v", i, err)
+		}
+	}
+	if tr.verify != 1 {
+		t.Fatalf("session must authenticate once, got %d", tr.verify)
+	}
+
+	closed := make(chan error, 1)
+	go func() { closed <- dev.Close() }()
+	select {
+	case <-closed:
+		t.Fatal("Close must wait for the session to be released")
+	case <-time.After(20 * time.Millisecond):
+	}
+	if err := sess.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-closed; err != nil {
+		t.Fatal(err)
+	}
+	if err := sess.Release(); err != nil {
+		t.Fatalf("second Release: %v", err)
+	}
+	if err := sess.WritePixels(context.Background(), pixels); err == nil {
+		t.Fatal("expected error writing on a released session")
+	}
+}
+
+func TestDeviceClosed(t *testing.T) {
+	profile := PresetProfiles[Product29Mono]
+	dev, _ := OpenTransport(profile, NewSimulator(profile))
+	if err := dev.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := dev.WritePixels(context.Background(), make([]uint8, profile.Width*profile.Height)); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+	if _, err := dev.UID(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed from UID, got %v", err)
+	}
+	if _, err := dev.BeginSession(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed from BeginSession, got %v", err)
+	}
+	if err := dev.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+}
@@ -0,0 +1,302 @@
+package ezsignnfc
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"sync"
+	"time"
+)
+
+// Simulator is a software EZ-Sign tag implementing Transport.
+// It accepts the same command sequence Device sends, decodes the F0D3
+// image data and exposes the refreshed panel as an image.
+type Simulator struct {
+	mu             sync.Mutex
+	profile        Profile
+	refreshLatency time.Duration
+
+	uid           []byte
+	atr           []byte
+	pin           PIN
+	pinTries      int
+	authenticated bool
+	pending       map[int][]byte
+	nextFrag      map[int]int
+	framebuffer   []uint8
+	panel         []uint8
+	received      []bool
+	fullFrame     bool
+	refreshUntil  time.Time
+	refreshing    bool
+	refreshes     int
+	closed        bool
+}
+
+const simulatorPINTries = 3
+
+// NewSimulator returns a simulated tag for profile with a white panel.
+func NewSimulator(profile Profile) *Simulator {
+	size := profile.Width * profile.Height
+	s := &Simulator{
+		profile:     profile,
+		uid:         []byte{0x04, 0x5A, 0x1C, 0x22, 0x91, 0x6B, 0x80},
+		atr:         []byte{0x3B, 0x80, 0x80, 0x01, 0x01},
+		pin:         DefaultPIN,
+		pinTries:    simulatorPINTries,
+		pending:     make(map[int][]byte),
+		nextFrag:    make(map[int]int),
+		framebuffer: make([]uint8, size),
+		panel:       make([]uint8, size),
+		received:    make([]bool, profile.BlockCount()),
+	}
+	for i := range s.framebuffer {
+		s.framebuffer[i] = ColorWhite
+		s.panel[i] = ColorWhite
+	}
+	return s
+}
+
+// SetRefreshLatency sets how long the simulated panel reports busy after F0D4.
+func (s *Simulator) SetRefreshLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refreshLatency = d
+}
+
+// SetRequireFullFrame makes the simulated tag reject F0D4 with 6985 unless
+// every block was written since the previous refresh, like tags that do not
+// keep untouched blocks.
+func (s *Simulator) SetRequireFullFrame(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fullFrame = enabled
+}
+
+// SetIdentity sets the UID returned by GET DATA and the ATR of the simulated tag.
+func (s *Simulator) SetIdentity(uid, atr []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.uid = append([]byte(nil), uid...)
+	s.atr = append([]byte(nil), atr...)
+}
+
+// ATR implements ATRProvider.
+func (s *Simulator) ATR() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]byte(nil), s.atr...), nil
+}
+
+// ReaderName implements Transport.
+func (s *Simulator) ReaderName() string {
+	return "EZ-Sign Simulator"
+}
+
+// Close implements Transport.
+func (s *Simulator) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+// Transmit implements Transport. It returns response data followed by SW1 SW2.
+func (s *Simulator) Transmit(apdu []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, fmt.Errorf("simulator closed")
+	}
+	if len(apdu) < 4 {
+		return sw(0x67, 0x00), nil
+	}
+	s.updateRefresh()
+
+	switch {
+	case apdu[0] == 0x00 && apdu[1] == 0x20:
+		return s.handleVerify(apdu), nil
+	case apdu[0] == 0x00 && apdu[1] == 0x24:
+		return s.handleChangePIN(apdu), nil
+	case apdu[0] == 0xFF && apdu[1] == 0xCA:
+		return s.handleGetData(apdu), nil
+	case apdu[0] != 0xF0:
+		return sw(0x6E, 0x00), nil
+	case apdu[1] == 0xD3:
+		return s.handleImageData(apdu), nil
+	case apdu[1] == 0xD4:
+		return s.handleStartRefresh(), nil
+	case apdu[1] == 0xDE:
+		return s.handlePollStatus(), nil
+	default:
+		return sw(0x6D, 0x00), nil
+	}
+}
+
+// Pixels returns a copy of the indexed pixels currently shown on the panel.
+func (s *Simulator) Pixels() []uint8 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]uint8(nil), s.panel...)
+}
+
+// Image returns the panel currently shown as a paletted image.
+func (s *Simulator) Image() image.Image {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	palette := make(color.Palette, 0, s.profile.Colors())
+	for _, c := range paletteForProfile(s.profile) {
+		palette = append(palette, c)
+	}
+	img := image.NewPaletted(image.Rect(0, 0, s.profile.Width, s.profile.Height), palette)
+	copy(img.Pix, s.panel)
+	return img
+}
+
+// Refreshes returns how many refreshes have completed.
+func (s *Simulator) Refreshes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.refreshes
+}
+
+func (s *Simulator) handleVerify(apdu []byte) []byte {
+	if len(apdu) < 5 || int(apdu[4]) != len(apdu)-5 {
+		return sw(0x67, 0x00)
+	}
+	if apdu[3] != 0x01 {
+		return sw(0x6A, 0x88)
+	}
+	var pin PIN
+	if len(apdu)-5 != len(pin) {
+		return sw(0x67, 0x00)
+	}
+	copy(pin[:], apdu[5:])
+	return s.checkPIN(pin)
+}
+
+func (s *Simulator) handleChangePIN(apdu []byte) []byte {
+	var oldPIN, newPIN PIN
+	if len(apdu) != 5+len(oldPIN)+len(newPIN) || int(apdu[4]) != len(apdu)-5 {
+		return sw(0x67, 0x00)
+	}
+	if apdu[3] != 0x01 {
+		return sw(0x6A, 0x88)
+	}
+	copy(oldPIN[:], apdu[5:])
+	copy(newPIN[:], apdu[5+len(oldPIN):])
+	if resp := s.checkPIN(oldPIN); resp[0] != 0x90 {
+		return resp
+	}
+	s.pin = newPIN
+	return sw(0x90, 0x00)
+}
+
+func (s *Simulator) handleGetData(apdu []byte) []byte {
+	if apdu[2] != 0x00 || apdu[3] != 0x00 {
+		// Only the UID (P1=00) is supported, not historical bytes.
+		return sw(0x6A, 0x81)
+	}
+	return append(append([]byte(nil), s.uid...), 0x90, 0x00)
+}
+
+func (s *Simulator) checkPIN(pin PIN) []byte {
+	if s.pinTries == 0 {
+		s.authenticated = false
+		return sw(0x69, 0x83)
+	}
+	if pin != s.pin {
+		s.authenticated = false
+		s.pinTries--
+		return sw(0x63, 0xC0|byte(s.pinTries))
+	}
+	s.pinTries = simulatorPINTries
+	s.authenticated = true
+	return sw(0x90, 0x00)
+}
+
+func (s *Simulator) handleImageData(apdu []byte) []byte {
+	if !s.authenticated {
+		return sw(0x69, 0x82)
+	}
+	if s.refreshing {
+		return sw(0x69, 0x85)
+	}
+	blockNo, fragNo, payload, isLast, err := parseImageDataAPDU(apdu)
+	if err != nil {
+		if len(apdu) >= 4 && apdu[3] > 0x01 {
+			return sw(0x6A, 0x86)
+		}
+		return sw(0x67, 0x00)
+	}
+	if blockNo >= s.profile.BlockCount() {
+		return sw(0x6A, 0x80)
+	}
+	if fragNo == 0 {
+		s.pending[blockNo] = nil
+		s.nextFrag[blockNo] = 0
+	}
+	if fragNo != s.nextFrag[blockNo] {
+		return sw(0x6A, 0x80)
+	}
+	s.pending[blockNo] = append(s.pending[blockNo], payload...)
+	s.nextFrag[blockNo] = fragNo + 1
+
+	if isLast {
+		data := s.pending[blockNo]
+		delete(s.pending, blockNo)
+		delete(s.nextFrag, blockNo)
+		raw, err := decompressLZO1X(data, s.profile.BytesPerRow()*blockRows)
+		if err != nil {
+			return sw(0x6A, 0x80)
+		}
+		if err := unpackBlockToRows(s.profile, raw, s.framebuffer, blockNo); err != nil {
+			return sw(0x6A, 0x80)
+		}
+		s.received[blockNo] = true
+	}
+	return sw(0x90, 0x00)
+}
+
+func (s *Simulator) handleStartRefresh() []byte {
+	if !s.authenticated {
+		return sw(0x69, 0x82)
+	}
+	if s.refreshing {
+		return sw(0x69, 0x85)
+	}
+	if s.fullFrame {
+		for _, ok := range s.received {
+			if !ok {
+				return sw(0x69, 0x85)
+			}
+		}
+	}
+	for i := range s.received {
+		s.received[i] = false
+	}
+	s.refreshing = true
+	s.refreshUntil = time.Now().Add(s.refreshLatency)
+	s.updateRefresh()
+	return sw(0x90, 0x00)
+}
+
+func (s *Simulator) handlePollStatus() []byte {
+	if s.refreshing {
+		return []byte{0x01, 0x90, 0x00}
+	}
+	return []byte{0x00, 0x90, 0x00}
+}
+
+func (s *Simulator) updateRefresh() {
+	if !s.refreshing || time.Now().Before(s.refreshUntil) {
+		return
+	}
+	copy(s.panel, s.framebuffer)
+	s.refreshing = false
+	s.refreshes++
+}
+
+func sw(sw1, sw2 byte) []byte {
+	return []byte{sw1, sw2}
+}
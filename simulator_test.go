@@ -0,0 +1,125 @@
+package ezsignnfc
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+	"time"
+)
+
+func TestSimulatorRoundTripPixels(t *testing.T) {
+	for _, product := range []Product{Product29Mono, Product29Quad, Product42Mono, Product42Quad} {
+		t.Run(string(product), func(t *testing.T) {
+			profile := PresetProfiles[product]
+			sim := NewSimulator(profile)
+			sim.SetRefreshLatency(5 * time.Millisecond)
+			dev, err := OpenTransport(profile, sim)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := dev.SetPolling(time.Millisecond, 100); err != nil {
+				t.Fatal(err)
+			}
+
+			pixels := make([]uint8, profile.Width*profile.Height)
+			for i := range pixels {
+				pixels[i] = uint8((i*7 + i/profile.Width) % profile.Colors())
+			}
+			if err := dev.WritePixels(context.Background(), pixels); err != nil {
+				t.Fatalf("WritePixels: %v", err)
+			}
+
+			got := sim.Pixels()
+			for i := range pixels {
+				if got[i] != pixels[i] {
+					t.Fatalf("pixel %d: got %d want %d", i, got[i], pixels[i])
+				}
+			}
+			if sim.Refreshes() != 1 {
+				t.Fatalf("refresh count: got %d want 1", sim.Refreshes())
+			}
+		})
+	}
+}
+
+func TestSimulatorWriteImage(t *testing.T) {
+	profile := PresetProfiles[Product29Quad]
+	sim := NewSimulator(profile)
+	dev, err := OpenTransport(profile, sim)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := image.NewNRGBA(image.Rect(0, 0, profile.Width, profile.Height))
+	for y := 0; y < profile.Height; y++ {
+		for x := 0; x < profile.Width; x++ {
+			c := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+			if x < profile.Width/2 {
+				c = color.NRGBA{R: 255, G: 0, B: 0, A: 255}
+			}
+			src.Set(x, y, c)
+		}
+	}
+	if err := dev.WriteImage(context.Background(), src); err != nil {
+		t.Fatalf("WriteImage: %v", err)
+	}
+
+	want := QuantizeImageToPixels(profile, src)
+	img := sim.Image()
+	if img.Bounds() != image.Rect(0, 0, profile.Width, profile.Height) {
+		t.Fatalf("image bounds: got %v", img.Bounds())
+	}
+	palette := paletteForProfile(profile)
+	for y := 0; y < profile.Height; y++ {
+		for x := 0; x < profile.Width; x++ {
+			wantC := palette[want[y*profile.Width+x]]
+			if got := color.NRGBAModel.Convert(img.At(x, y)); got != wantC {
+				t.Fatalf("pixel (%d,%d): got %v want %v", x, y, got, wantC)
+			}
+		}
+	}
+}
+
+func TestSimulatorStatusWords(t *testing.T) {
+	profile := PresetProfiles[Product29Mono]
+	sim := NewSimulator(profile)
+
+	apdu, err := buildImageDataAPDU(0, 0, []byte{0x11, 0x00, 0x00}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := sim.Transmit(apdu)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp[0] != 0x69 || resp[1] != 0x82 {
+		t.Fatalf("image data before authenticate: got %X want 6982", resp)
+	}
+
+	resp, _ = sim.Transmit([]byte{0xF0, 0xAA, 0x00, 0x00, 0x00})
+	if resp[0] != 0x6D || resp[1] != 0x00 {
+		t.Fatalf("unknown instruction: got %X want 6D00", resp)
+	}
+
+	resp, _ = sim.Transmit(buildVerifyAPDU(DefaultPIN))
+	if resp[0] != 0x90 || resp[1] != 0x00 {
+		t.Fatalf("authenticate: got %X want 9000", resp)
+	}
+	apdu, _ = buildImageDataAPDU(profile.BlockCount(), 0, []byte{0x11, 0x00, 0x00}, true)
+	resp, _ = sim.Transmit(apdu)
+	if resp[0] != 0x6A || resp[1] != 0x80 {
+		t.Fatalf("block out of range: got %X want 6A80", resp)
+	}
+}
+
+func TestSimulatorRefreshLatency(t *testing.T) {
+	sim := NewSimulator(PresetProfiles[Product29Mono])
+	sim.SetRefreshLatency(time.Hour)
+	sim.Transmit(buildVerifyAPDU(DefaultPIN))
+	sim.Transmit(apduStartRefresh)
+	resp, _ := sim.Transmit(apduPollStatus)
+	if len(resp) != 3 || resp[0] != 0x01 {
+		t.Fatalf("poll during refresh: got %X want 019000", resp)
+	}
+}
@@ -0,0 +1,162 @@
+package ezsignnfc
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// abortGrace bounds how long the watchdog waits for an aborted transmit to
+// return before it abandons the transport.
+var abortGrace = 2 * time.Second
+
+// aborter is implemented by transports that can interrupt a pending
+// Transmit. Abort is called while Transmit may still be running, on another
+// goroutine; it must not wait for Transmit to return.
+type aborter interface {
+	Abort() error
+}
+
+// SetTimeouts bounds each APDU exchange by apdu and each operation (a write,
+// ChangePIN or Transmit call) by operation. Zero disables a limit. When a
+// limit expires inside a transmit, the watchdog asks the transport to abort
+// it and the call fails with a *TimeoutError. If the transport does not
+// return within a grace period, the device gives it up and later operations
+// fail with ErrClosed.
+func (d *Device) SetTimeouts(apdu, operation time.Duration) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := checkTimeouts(apdu, operation); err != nil {
+		return err
+	}
+	d.apduTimeout = apdu
+	d.opTimeout = operation
+	return nil
+}
+
+func checkTimeouts(apdu, operation time.Duration) error {
+	if apdu < 0 || operation < 0 {
+		return fmt.Errorf("timeouts must be >= 0")
+	}
+	return nil
+}
+
+// operationContext applies the operation timeout to ctx. Its cause is a
+// *TimeoutError so that expiry is reported as a timeout, not as
+// context.DeadlineExceeded.
+func (d *Device) operationContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d.opTimeout <= 0 {
+		return ctx, func() {}
+	}
+	cause := &TimeoutError{Limit: d.opTimeout, Operation: true}
+	return context.WithTimeoutCause(ctx, d.opTimeout, cause)
+}
+
+// transmitResult is the outcome of a Transmit running on a watchdog goroutine.
+type transmitResult struct {
+	resp []byte
+	err  error
+}
+
+// transmitWatched runs Transport.Transmit under the per-APDU timeout and ctx.
+// On expiry it aborts the pending call and returns the timeout or ctx cause.
+// Without a per-APDU timeout the call stays on the caller's goroutine, so
+// ctx interrupts it only when the transport's abort makes Transmit return.
+func (d *Device) transmitWatched(ctx context.Context, command string, apdu []byte) ([]byte, error) {
+	if d.apduTimeout > 0 {
+		return d.transmitDeadline(ctx, command, apdu)
+	}
+	a, ok := d.transport.(aborter)
+	if !ok || ctx.Done() == nil {
+		return d.transport.Transmit(apdu)
+	}
+	aborted := make(chan error, 1)
+	stop := context.AfterFunc(ctx, func() { aborted <- a.Abort() })
+	resp, err := d.transport.Transmit(apdu)
+	if stop() {
+		return resp, err
+	}
+	return nil, withAbortError(commandCause(ctx, command), pendingAbortError(aborted))
+}
+
+// transmitDeadline runs Transmit on its own goroutine so that a call the
+// transport does not give up on can be abandoned after abortGrace.
+func (d *Device) transmitDeadline(ctx context.Context, command string, apdu []byte) ([]byte, error) {
+	t := d.transport
+	done := make(chan transmitResult, 1)
+	go func() {
+		resp, err := t.Transmit(apdu)
+		done <- transmitResult{resp, err}
+	}()
+
+	timer := time.NewTimer(d.apduTimeout)
+	defer timer.Stop()
+	var cause error
+	select {
+	case r := <-done:
+		return r.resp, r.err
+	case <-timer.C:
+		cause = &TimeoutError{Command: command, Limit: d.apduTimeout}
+	case <-ctx.Done():
+		cause = commandCause(ctx, command)
+	}
+
+	// The grace period starts now and Abort runs on its own goroutine, so a
+	// transport whose Abort waits for the stuck Transmit is still abandoned.
+	grace := time.NewTimer(abortGrace)
+	defer grace.Stop()
+	aborted := make(chan error, 1)
+	if a, ok := t.(aborter); ok {
+		go func() { aborted <- a.Abort() }()
+	}
+	select {
+	case <-done:
+		return nil, withAbortError(cause, pendingAbortError(aborted))
+	case <-grace.C:
+	}
+	d.abandonTransport(done)
+	return nil, fmt.Errorf("%w; transmit still running: %w", cause, ErrClosed)
+}
+
+// pendingAbortError returns the result of an Abort that has already
+// finished, without waiting for one that is still running.
+func pendingAbortError(aborted <-chan error) error {
+	select {
+	case err := <-aborted:
+		return err
+	default:
+		return nil
+	}
+}
+
+// abandonTransport detaches a transport whose Transmit is still running
+// after an abort. Later operations fail with ErrClosed instead of sharing
+// it, and the transport is closed once the stuck call returns.
+func (d *Device) abandonTransport(done <-chan transmitResult) {
+	t := d.transport
+	d.transport = nil
+	d.logger.LogAttrs(context.Background(), slog.LevelError, "transmit did not stop after abort, device closed",
+		slog.String("reader", d.reader))
+	go func() {
+		<-done
+		t.Close()
+	}()
+}
+
+// commandCause returns the cause of ctx, naming command when the operation
+// deadline expired.
+func commandCause(ctx context.Context, command string) error {
+	cause := context.Cause(ctx)
+	if te, ok := cause.(*TimeoutError); ok {
+		return &TimeoutError{Command: command, Limit: te.Limit, Operation: true}
+	}
+	return cause
+}
+
+func withAbortError(cause, abortErr error) error {
+	if abortErr == nil {
+		return cause
+	}
+	return fmt.Errorf("%w (abort: %v)", cause, abortErr)
+}
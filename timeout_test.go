@@ -0,0 +1,218 @@
+package ezsignnfc
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stallTransport hangs on one instruction until Abort is called.
+type stallTransport struct {
+	*Simulator
+	ins     byte
+	release chan struct{}
+	aborts  atomic.Int32
+}
+
+func (s *stallTransport) Transmit(apdu []byte) ([]byte, error) {
+	if len(apdu) > 1 && apdu[1] == s.ins {
+		<-s.release
+		return nil, errors.New("transmit cancelled")
+	}
+	return s.Simulator.Transmit(apdu)
+}
+
+func (s *stallTransport) Abort() error {
+	if s.aborts.Add(1) == 1 {
+		close(s.release)
+	}
+	return nil
+}
+
+func TestDeviceAPDUTimeout(t *testing.T) {
+	profile := PresetProfiles[Product29Mono]
+	tr := &stallTransport{Simulator: NewSimulator(profile), ins: 0xD3, release: make(chan struct{})}
+	dev, _ := OpenTransport(profile, tr, WithTimeouts(20*time.Millisecond, 0))
+
+	err := dev.WritePixels(context.Background(), make([]uint8, profile.Width*profile.Height))
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+	var te *TimeoutError
+	if !errors.As(err, &te) || te.Command != CommandImageData || te.Operation || te.Limit != 20*time.Millisecond {
+		t.Fatalf("unexpected timeout error: %#v", te)
+	}
+	if tr.aborts.Load() != 1 {
+		t.Fatalf("aborts: got %d want 1", tr.aborts.Load())
+	}
+}
+
+func TestDeviceOperationTimeout(t *testing.T) {
+	profile := PresetProfiles[Product29Mono]
+	sim := NewSimulator(profile)
+	sim.SetRefreshLatency(time.Second)
+	dev, _ := OpenTransport(profile, sim)
+	if err := dev.SetTimeouts(0, 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	dev.SetPolling(10*time.Millisecond, 1000)
+
+	start := time.Now()
+	err := dev.WritePixels(context.Background(), make([]uint8, profile.Width*profile.Height))
+	var te *TimeoutError
+	if !errors.As(err, &te) || !te.Operation {
+		t.Fatalf("expected operation timeout, got %v", err)
+	}
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("errors.Is(ErrTimeout) failed for %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("operation deadline not honoured: %s", elapsed)
+	}
+
+	if err := dev.SetTimeouts(-time.Second, 0); err == nil {
+		t.Fatal("expected error for negative timeout")
+	}
+}
+
+func TestDeviceContextCancelInsideTransmit(t *testing.T) {
+	profile := PresetProfiles[Product29Mono]
+	tr := &stallTransport{Simulator: NewSimulator(profile), ins: 0x20, release: make(chan struct{})}
+	dev, _ := OpenTransport(profile, tr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	err := dev.WritePixels(ctx, make([]uint8, profile.Width*profile.Height))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if tr.aborts.Load() != 1 {
+		t.Fatalf("aborts: got %d want 1", tr.aborts.Load())
+	}
+}
+
+// hangTransport delays one instruction until release is closed and cannot
+// abort. It reports calls that overlap.
+type hangTransport struct {
+	*Simulator
+	ins      byte
+	release  chan struct{}
+	inflight atomic.Int32
+	overlap  atomic.Bool
+	closed   atomic.Bool
+}
+
+func (h *hangTransport) Transmit(apdu []byte) ([]byte, error) {
+	if h.inflight.Add(1) > 1 {
+		h.overlap.Store(true)
+	}
+	defer h.inflight.Add(-1)
+	if len(apdu) > 1 && apdu[1] == h.ins {
+		<-h.release
+	}
+	return h.Simulator.Transmit(apdu)
+}
+
+func (h *hangTransport) Close() error {
+	h.closed.Store(true)
+	return nil
+}
+
+func TestDeviceAbandonsStuckTransport(t *testing.T) {
+	saved := abortGrace
+	abortGrace = 30 * time.Millisecond
+	defer func() { abortGrace = saved }()
+
+	profile := PresetProfiles[Product29Mono]
+	tr := &hangTransport{Simulator: NewSimulator(profile), ins: 0xD3, release: make(chan struct{})}
+	dev, _ := OpenTransport(profile, tr, WithTimeouts(20*time.Millisecond, 0))
+	dev.SetRetryBudget(1)
+
+	pixels := make([]uint8, profile.Width*profile.Height)
+	err := dev.WritePixels(context.Background(), pixels)
+	if !errors.Is(err, ErrTimeout) || !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected timeout and ErrClosed, got %v", err)
+	}
+	if err := dev.WritePixels(context.Background(), pixels); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed after abandon, got %v", err)
+	}
+	if tr.overlap.Load() {
+		t.Fatal("transmit overlapped the stuck call")
+	}
+
+	close(tr.release)
+	deadline := time.Now().Add(time.Second)
+	for !tr.closed.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("abandoned transport was not closed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDeviceContextCancelWithoutAbort(t *testing.T) {
+	profile := PresetProfiles[Product29Mono]
+	tr := &hangTransport{Simulator: NewSimulator(profile), ins: 0x20, release: make(chan struct{})}
+	dev, _ := OpenTransport(profile, tr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, func() {
+		cancel()
+		time.AfterFunc(20*time.Millisecond, func() { close(tr.release) })
+	})
+	// The transport cannot abort, so the call waits for it before failing.
+	if err := dev.WritePixels(ctx, make([]uint8, profile.Width*profile.Height)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if tr.inflight.Load() != 0 {
+		t.Fatal("WritePixels returned while the transport was still busy")
+	}
+	if _, err := dev.UID(context.Background()); err != nil {
+		t.Fatalf("device unusable after cancel: %v", err)
+	}
+}
+
+// serialTransport models pcsclite: Abort cannot interrupt the stuck Transmit
+// and blocks until it returns.
+type serialTransport struct {
+	*hangTransport
+	returned chan struct{}
+}
+
+func (s *serialTransport) Transmit(apdu []byte) ([]byte, error) {
+	resp, err := s.hangTransport.Transmit(apdu)
+	if len(apdu) > 1 && apdu[1] == s.ins {
+		close(s.returned)
+	}
+	return resp, err
+}
+
+func (s *serialTransport) Abort() error {
+	<-s.returned
+	return nil
+}
+
+func TestDeviceTimeoutWithBlockingAbort(t *testing.T) {
+	saved := abortGrace
+	abortGrace = 30 * time.Millisecond
+	defer func() { abortGrace = saved }()
+
+	profile := PresetProfiles[Product29Mono]
+	tr := &serialTransport{
+		hangTransport: &hangTransport{Simulator: NewSimulator(profile), ins: 0xD3, release: make(chan struct{})},
+		returned:      make(chan struct{}),
+	}
+	defer close(tr.release)
+	dev, _ := OpenTransport(profile, tr, WithTimeouts(20*time.Millisecond, 0))
+
+	start := time.Now()
+	err := dev.WritePixels(context.Background(), make([]uint8, profile.Width*profile.Height))
+	if !errors.Is(err, ErrTimeout) || !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected timeout and ErrClosed, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("deadline not honoured while Abort blocks: %s", elapsed)
+	}
+}
@@ -0,0 +1,360 @@
+package ezsignnfc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Trace files are line-oriented so they can be diffed:
+//
+//	# ezsign-trace v1
+//	# reader: SONY FeliCa Port/PaSoRi 4.0
+//	# profile: product=4.2-4c width=400 height=300 bpp=2
+//	> 2026-01-02T03:04:05.000000006Z 0020000104...
+//	< 2026-01-02T03:04:05.010000000Z 9000
+//	! 2026-01-02T03:04:05.020000000Z card-removed scard: ...
+//
+// '>' lines are commands, '<' lines are responses including SW1 SW2 and '!'
+// lines are transport errors. Timestamps are UTC RFC 3339. The PIN bytes of
+// VERIFY and CHANGE REFERENCE DATA are written as '*', so traces can be
+// shared; replay matches such commands by header and length.
+const traceMagic = "# ezsign-trace v1"
+
+const traceTimeLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+// TraceRecorder is a Transport that records every exchange of an inner
+// transport to a writer.
+type TraceRecorder struct {
+	mu    sync.Mutex
+	inner Transport
+	w     io.Writer
+	err   error
+}
+
+// NewTraceRecorder wraps inner and writes the trace header for profile to w.
+func NewTraceRecorder(w io.Writer, inner Transport, profile Profile) (*TraceRecorder, error) {
+	if inner == nil {
+		return nil, fmt.Errorf("transport must not be nil")
+	}
+	header := fmt.Sprintf("%s\n# reader: %s\n# profile: product=%s width=%d height=%d bpp=%d\n",
+		traceMagic, inner.ReaderName(), profile.Product, profile.Width, profile.Height, profile.BitsPerPixel)
+	if _, err := io.WriteString(w, header); err != nil {
+		return nil, fmt.Errorf("write trace header: %w", err)
+	}
+	return &TraceRecorder{inner: inner, w: w}, nil
+}
+
+// Transmit implements Transport.
+func (r *TraceRecorder) Transmit(apdu []byte) ([]byte, error) {
+	r.writeLine('>', redactedHex("", apdu))
+	resp, err := r.inner.Transmit(apdu)
+	if err != nil {
+		kind := "error"
+		if errors.Is(err, ErrCardRemoved) {
+			kind = "card-removed"
+		}
+		r.writeLine('!', kind+" "+strings.ReplaceAll(err.Error(), "\n", " "))
+		return nil, err
+	}
+	r.writeLine('<', strings.ToUpper(hex.EncodeToString(resp)))
+	return resp, nil
+}
+
+// Close implements Transport and closes the inner transport.
+func (r *TraceRecorder) Close() error {
+	return r.inner.Close()
+}
+
+// BeginTransaction forwards to the inner transport when it supports transactions.
+func (r *TraceRecorder) BeginTransaction() error {
+	if tx, ok := r.inner.(transactor); ok {
+		return tx.BeginTransaction()
+	}
+	return nil
+}
+
+// EndTransaction forwards to the inner transport when it supports transactions.
+func (r *TraceRecorder) EndTransaction() error {
+	if tx, ok := r.inner.(transactor); ok {
+		return tx.EndTransaction()
+	}
+	return nil
+}
+
+// Abort forwards to the inner transport when it can abort a transmit.
+func (r *TraceRecorder) Abort() error {
+	if a, ok := r.inner.(aborter); ok {
+		return a.Abort()
+	}
+	return nil
+}
+
+// Reconnect forwards to the inner transport, so that recording a trace
+// keeps auto reconnect working.
+func (r *TraceRecorder) Reconnect(ctx context.Context) error {
+	rec, ok := r.inner.(Reconnector)
+	if !ok {
+		return fmt.Errorf("transport %q cannot reconnect", r.inner.ReaderName())
+	}
+	return rec.Reconnect(ctx)
+}
+
+// ATR implements ATRProvider when the inner transport provides an ATR.
+func (r *TraceRecorder) ATR() ([]byte, error) {
+	return transportATR(r.inner)
+}
+
+// ReaderName implements Transport.
+func (r *TraceRecorder) ReaderName() string {
+	return r.inner.ReaderName()
+}
+
+// Err returns the first error hit while writing the trace.
+func (r *TraceRecorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *TraceRecorder) writeLine(dir byte, body string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	line := fmt.Sprintf("%c %s %s\n", dir, time.Now().UTC().Format(traceTimeLayout), body)
+	if _, err := io.WriteString(r.w, line); err != nil {
+		r.err = fmt.Errorf("write trace: %w", err)
+	}
+}
+
+// TraceEntry is one recorded command and its outcome.
+type TraceEntry struct {
+	// Command holds zeros for bytes that were redacted in the trace.
+	Command  []byte
+	Redacted bool
+	Response []byte
+	Err      error
+	Sent     time.Time
+	Received time.Time
+
+	// plain is the number of leading command bytes that were not redacted.
+	plain int
+}
+
+// ReplayTransport serves the responses of a recorded trace in order.
+// Each command must match the recorded one.
+type ReplayTransport struct {
+	mu      sync.Mutex
+	reader  string
+	profile Profile
+	entries []TraceEntry
+	next    int
+}
+
+// NewReplayTransport parses a trace written by TraceRecorder.
+func NewReplayTransport(r io.Reader) (*ReplayTransport, error) {
+	t := &ReplayTransport{}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	sawMagic := false
+	var pending *TraceEntry
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		if line[0] == '#' {
+			if lineNo == 1 && line == traceMagic {
+				sawMagic = true
+				continue
+			}
+			if err := t.parseHeader(line); err != nil {
+				return nil, fmt.Errorf("trace line %d: %w", lineNo, err)
+			}
+			continue
+		}
+		if !sawMagic {
+			return nil, fmt.Errorf("trace line %d: missing %q header", lineNo, traceMagic)
+		}
+
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("trace line %d: malformed entry", lineNo)
+		}
+		ts, err := time.Parse(time.RFC3339Nano, fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("trace line %d: bad timestamp: %w", lineNo, err)
+		}
+		body := ""
+		if len(fields) == 3 {
+			body = fields[2]
+		}
+
+		switch fields[0] {
+		case ">":
+			if pending != nil {
+				return nil, fmt.Errorf("trace line %d: command without response", lineNo)
+			}
+			cmd, plain, err := parseTraceCommand(body)
+			if err != nil {
+				return nil, fmt.Errorf("trace line %d: bad command hex: %w", lineNo, err)
+			}
+			pending = &TraceEntry{Command: cmd, Redacted: plain < len(cmd), Sent: ts, plain: plain}
+		case "<", "!":
+			if pending == nil {
+				return nil, fmt.Errorf("trace line %d: response without command", lineNo)
+			}
+			pending.Received = ts
+			if fields[0] == "<" {
+				if pending.Response, err = hex.DecodeString(body); err != nil {
+					return nil, fmt.Errorf("trace line %d: bad response hex: %w", lineNo, err)
+				}
+			} else {
+				pending.Err = parseTraceError(body)
+			}
+			t.entries = append(t.entries, *pending)
+			pending = nil
+		default:
+			return nil, fmt.Errorf("trace line %d: unknown direction %q", lineNo, fields[0])
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read trace: %w", err)
+	}
+	if !sawMagic {
+		return nil, fmt.Errorf("missing %q header", traceMagic)
+	}
+	if pending != nil {
+		return nil, fmt.Errorf("trace ends with a command without response")
+	}
+	return t, nil
+}
+
+func (t *ReplayTransport) parseHeader(line string) error {
+	key, value, ok := strings.Cut(strings.TrimSpace(strings.TrimPrefix(line, "#")), ":")
+	if !ok {
+		return nil
+	}
+	value = strings.TrimSpace(value)
+	switch strings.TrimSpace(key) {
+	case "reader":
+		t.reader = value
+	case "profile":
+		for _, kv := range strings.Fields(value) {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok {
+				return fmt.Errorf("bad profile field %q", kv)
+			}
+			var err error
+			switch k {
+			case "product":
+				t.profile.Product = Product(v)
+			case "width":
+				t.profile.Width, err = strconv.Atoi(v)
+			case "height":
+				t.profile.Height, err = strconv.Atoi(v)
+			case "bpp":
+				t.profile.BitsPerPixel, err = strconv.Atoi(v)
+			}
+			if err != nil {
+				return fmt.Errorf("bad profile field %q: %w", kv, err)
+			}
+		}
+	}
+	return nil
+}
+
+// parseTraceCommand decodes a command, treating trailing '*' pairs as
+// redacted bytes. plain is the number of bytes before the redaction.
+func parseTraceCommand(body string) (cmd []byte, plain int, err error) {
+	text, masked, redacted := strings.Cut(body, "*")
+	cmd, err = hex.DecodeString(text)
+	if err != nil || !redacted {
+		return cmd, len(cmd), err
+	}
+	masked = "*" + masked
+	if strings.Trim(masked, "*") != "" || len(masked)%2 != 0 {
+		return nil, 0, fmt.Errorf("malformed redaction %q", body)
+	}
+	return append(cmd, make([]byte, len(masked)/2)...), len(cmd), nil
+}
+
+func parseTraceError(body string) error {
+	kind, msg, _ := strings.Cut(body, " ")
+	if kind == "card-removed" {
+		return fmt.Errorf("%w: replayed: %s", ErrCardRemoved, msg)
+	}
+	return fmt.Errorf("replayed: %s", msg)
+}
+
+// Profile returns the profile recorded in the trace header.
+func (t *ReplayTransport) Profile() Profile {
+	return t.profile
+}
+
+// Entries returns the recorded exchanges.
+func (t *ReplayTransport) Entries() []TraceEntry {
+	return t.entries
+}
+
+// Remaining returns how many recorded exchanges have not been replayed.
+func (t *ReplayTransport) Remaining() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.entries) - t.next
+}
+
+// Transmit implements Transport.
+func (t *ReplayTransport) Transmit(apdu []byte) ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.next >= len(t.entries) {
+		return nil, fmt.Errorf("replay exhausted after %d exchanges", len(t.entries))
+	}
+	e := t.entries[t.next]
+	if !e.matches(apdu) {
+		return nil, fmt.Errorf("replay mismatch at exchange %d: got %X want %X", t.next+1, apdu, e.Command)
+	}
+	t.next++
+	if e.Err != nil {
+		return nil, e.Err
+	}
+	return append([]byte(nil), e.Response...), nil
+}
+
+// Reconnect implements Reconnector. The recorded session simply continues,
+// so traces of a recovered write replay as well.
+func (t *ReplayTransport) Reconnect(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// matches compares apdu with the recorded command. Redacted bytes match
+// anything, so a replay works whatever PIN the device uses.
+func (e *TraceEntry) matches(apdu []byte) bool {
+	if !e.Redacted {
+		return bytes.Equal(apdu, e.Command)
+	}
+	return len(apdu) == len(e.Command) && bytes.Equal(apdu[:e.plain], e.Command[:e.plain])
+}
+
+// Close implements Transport.
+func (t *ReplayTransport) Close() error {
+	return nil
+}
+
+// ReaderName implements Transport.
+func (t *ReplayTransport) ReaderName() string {
+	return t.reader
+}
@@ -0,0 +1,174 @@
+package ezsignnfc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTraceRecordAndReplay(t *testing.T) {
+	profile := PresetProfiles[Product29Quad]
+	pixels := make([]uint8, profile.Width*profile.Height)
+	for i := range pixels {
+		pixels[i] = uint8(i/profile.Width) % 4
+	}
+
+	var buf bytes.Buffer
+	dev, err := OpenTransport(profile, NewSimulator(profile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := dev.RecordTrace(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dev.WritePixels(context.Background(), pixels); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	trace := buf.String()
+	if !strings.HasPrefix(trace, traceMagic+"\n# reader: EZ-Sign Simulator\n# profile: product=2.9-4c width=296 height=128 bpp=2\n") {
+		t.Fatalf("unexpected trace header:\n%s", trace[:200])
+	}
+	if !strings.Contains(trace, "> ") || !strings.Contains(trace, "< ") {
+		t.Fatal("expected command and response lines")
+	}
+
+	replay, err := NewReplayTransport(strings.NewReader(trace))
+	if err != nil {
+		t.Fatalf("NewReplayTransport: %v", err)
+	}
+	if got := replay.Profile(); got != profile {
+		t.Fatalf("replay profile: got %+v want %+v", got, profile)
+	}
+	if first := replay.Entries()[0]; !first.Redacted || !bytes.Equal(first.Command[:5], buildVerifyAPDU(DefaultPIN)[:5]) {
+		t.Fatal("first entry must be a redacted authenticate")
+	}
+	replayed, err := OpenTransport(replay.Profile(), replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := replayed.WritePixels(context.Background(), pixels); err != nil {
+		t.Fatalf("replayed write: %v", err)
+	}
+	if replay.Remaining() != 0 {
+		t.Fatalf("replay remaining: %d", replay.Remaining())
+	}
+
+	replay, _ = NewReplayTransport(strings.NewReader(trace))
+	replayed, _ = OpenTransport(replay.Profile(), replay)
+	other := make([]uint8, len(pixels))
+	if err := replayed.WritePixels(context.Background(), other); err == nil {
+		t.Fatal("expected mismatch when replaying different pixels")
+	}
+}
+
+func TestTraceRedactsPIN(t *testing.T) {
+	profile := PresetProfiles[Product29Mono]
+	newPIN := PIN{0x11, 0x22, 0x33, 0x44}
+	dev, _ := OpenTransport(profile, NewSimulator(profile))
+	var buf bytes.Buffer
+	if _, err := dev.RecordTrace(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := dev.ChangePIN(context.Background(), DefaultPIN, newPIN); err != nil {
+		t.Fatal(err)
+	}
+	if err := dev.WritePixels(context.Background(), make([]uint8, profile.Width*profile.Height)); err != nil {
+		t.Fatal(err)
+	}
+	trace := buf.String()
+	for _, pin := range []PIN{DefaultPIN, newPIN} {
+		if strings.Contains(trace, pin.String()) {
+			t.Fatalf("pin %s leaked into trace:\n%s", pin, trace)
+		}
+	}
+	if !strings.Contains(trace, "0020000104********") {
+		t.Fatal("expected a redacted VERIFY")
+	}
+
+	// The replay accepts whatever PIN the replaying device sends.
+	replay, err := NewReplayTransport(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed, _ := OpenTransport(profile, replay)
+	if err := replayed.ChangePIN(context.Background(), PIN{1, 2, 3, 4}, PIN{5, 6, 7, 8}); err != nil {
+		t.Fatal(err)
+	}
+	if err := replayed.WritePixels(context.Background(), make([]uint8, profile.Width*profile.Height)); err != nil {
+		t.Fatalf("replayed write: %v", err)
+	}
+}
+
+func TestTraceRecordRecovery(t *testing.T) {
+	profile := PresetProfiles[Product29Mono]
+	pixels := make([]uint8, profile.Width*profile.Height)
+	setup := func(dev *Device) {
+		dev.SetRetryBudget(1)
+		dev.SetAutoReconnect(true)
+	}
+
+	// GET DATA and VERIFY pass, then the card leaves on the first image fragment.
+	tr := &flakyTransport{Simulator: NewSimulator(profile), failAt: 3, failErr: ErrCardRemoved}
+	dev, _ := OpenTransport(profile, tr)
+	setup(dev)
+	var buf bytes.Buffer
+	if _, err := dev.RecordTrace(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := dev.WritePixels(context.Background(), pixels); err != nil {
+		t.Fatalf("WritePixels while recording: %v", err)
+	}
+	if tr.reconnects != 1 {
+		t.Fatalf("reconnects: got %d want 1", tr.reconnects)
+	}
+	if !strings.Contains(buf.String(), "card-removed") {
+		t.Fatal("expected the removal in the trace")
+	}
+
+	replay, err := NewReplayTransport(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed, _ := OpenTransport(replay.Profile(), replay)
+	setup(replayed)
+	if err := replayed.WritePixels(context.Background(), pixels); err != nil {
+		t.Fatalf("replayed recovery: %v", err)
+	}
+	if replay.Remaining() != 0 {
+		t.Fatalf("replay remaining: %d", replay.Remaining())
+	}
+}
+
+func TestTraceReplayErrors(t *testing.T) {
+	trace := traceMagic + `
+# reader: R
+> 2026-01-02T03:04:05.000000000Z 0020000104200912 10
+! 2026-01-02T03:04:05.000000000Z card-removed scard: card removed
+`
+	if _, err := NewReplayTransport(strings.NewReader(trace)); err == nil {
+		t.Fatal("expected error for malformed hex")
+	}
+
+	trace = traceMagic + `
+> 2026-01-02T03:04:05.000000000Z 002000010420091210
+! 2026-01-02T03:04:05.000000000Z card-removed scard: card removed
+`
+	replay, err := NewReplayTransport(strings.NewReader(trace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := replay.Transmit(buildVerifyAPDU(DefaultPIN)); !errors.Is(err, ErrCardRemoved) {
+		t.Fatalf("expected replayed ErrCardRemoved, got %v", err)
+	}
+
+	if _, err := NewReplayTransport(strings.NewReader("> 2026-01-02T03:04:05Z 00\n")); err == nil {
+		t.Fatal("expected error for missing header")
+	}
+}
@@ -0,0 +1,161 @@
+package ezsignnfc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/ebfe/scard"
+)
+
+// Transport exchanges raw APDUs with an EZ-Sign tag.
+// Transmit returns the full response including the trailing SW1 SW2.
+type Transport interface {
+	Transmit(apdu []byte) ([]byte, error)
+	Close() error
+	ReaderName() string
+}
+
+// Reconnector is implemented by transports that can re-establish the card
+// connection after the card left the reader field. Reconnect blocks until
+// the card is back or ctx is done.
+type Reconnector interface {
+	Reconnect(ctx context.Context) error
+}
+
+// canReconnect reports whether t can reconnect, looking through a
+// TraceRecorder to the transport it records.
+func canReconnect(t Transport) bool {
+	if r, ok := t.(*TraceRecorder); ok {
+		return canReconnect(r.inner)
+	}
+	_, ok := t.(Reconnector)
+	return ok
+}
+
+// scardTransport is the default PC/SC transport backed by github.com/ebfe/scard.
+type scardTransport struct {
+	ctx      *scard.Context
+	card     *scard.Card
+	reader   string
+	share    ShareMode
+	protocol Protocol
+	// resetPending is set by Abort; the next Transmit resets the card first.
+	resetPending atomic.Bool
+}
+
+func (t *scardTransport) Transmit(apdu []byte) ([]byte, error) {
+	if t.card == nil {
+		return nil, fmt.Errorf("transport closed")
+	}
+	if t.resetPending.Swap(false) {
+		if err := t.card.Reconnect(t.share.scard(), t.protocol.scard(), scard.ResetCard); err != nil {
+			return nil, classifySCardError(err)
+		}
+	}
+	resp, err := t.card.Transmit(apdu)
+	if err != nil {
+		return nil, classifySCardError(err)
+	}
+	return resp, nil
+}
+
+// classifySCardError marks PC/SC errors caused by the card leaving the field
+// with ErrCardRemoved while keeping the original scard error in the chain.
+func classifySCardError(err error) error {
+	switch {
+	case errors.Is(err, scard.ErrRemovedCard),
+		errors.Is(err, scard.ErrNoSmartcard),
+		errors.Is(err, scard.ErrResetCard),
+		errors.Is(err, scard.ErrUnpoweredCard),
+		errors.Is(err, scard.ErrUnresponsiveCard):
+		return fmt.Errorf("%w: %w", ErrCardRemoved, err)
+	}
+	return err
+}
+
+// Abort cancels blocking calls on the PC/SC context and schedules a card
+// reset for the next Transmit. The transmit watchdog uses it when a Transmit
+// does not return in time. pcsclite serializes calls on a card handle and
+// SCardCancel does not interrupt SCardTransmit, so the reset cannot be done
+// here while the stuck call is still in flight.
+func (t *scardTransport) Abort() error {
+	if t.ctx == nil {
+		return nil
+	}
+	t.resetPending.Store(true)
+	return t.ctx.Cancel()
+}
+
+// Reconnect waits for a card to be placed on the same reader again and
+// replaces the stale card handle with a fresh connection.
+func (t *scardTransport) Reconnect(ctx context.Context) error {
+	if t.ctx == nil {
+		return fmt.Errorf("transport closed")
+	}
+	stop := cancelOnDone(ctx, t.ctx)
+	defer stop()
+
+	if _, err := waitCardPresent(ctx, t.ctx, []ReaderSelector{ReaderName(t.reader)}); err != nil {
+		return err
+	}
+	if t.card != nil {
+		t.card.Disconnect(scard.LeaveCard)
+		t.card = nil
+	}
+	card, err := t.ctx.Connect(t.reader, t.share.scard(), t.protocol.scard())
+	if err != nil {
+		return fmt.Errorf("reconnect reader %q: %w", t.reader, err)
+	}
+	t.card = card
+	t.resetPending.Store(false)
+	return nil
+}
+
+func (t *scardTransport) BeginTransaction() error {
+	if t.card == nil {
+		return fmt.Errorf("transport closed")
+	}
+	return classifySCardError(t.card.BeginTransaction())
+}
+
+func (t *scardTransport) EndTransaction() error {
+	if t.card == nil {
+		return nil
+	}
+	return classifySCardError(t.card.EndTransaction(scard.LeaveCard))
+}
+
+// ATR implements ATRProvider using the card status.
+func (t *scardTransport) ATR() ([]byte, error) {
+	if t.card == nil {
+		return nil, fmt.Errorf("transport closed")
+	}
+	status, err := t.card.Status()
+	if err != nil {
+		return nil, classifySCardError(err)
+	}
+	return status.Atr, nil
+}
+
+func (t *scardTransport) ReaderName() string {
+	return t.reader
+}
+
+func (t *scardTransport) Close() error {
+	var firstErr error
+	if t.card != nil {
+		if err := t.card.Disconnect(scard.ResetCard); err != nil {
+			firstErr = err
+		}
+		t.card = nil
+	}
+	if t.ctx != nil {
+		if err := t.ctx.Release(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		t.ctx = nil
+	}
+	return firstErr
+}
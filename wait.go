@@ -0,0 +1,190 @@
+package ezsignnfc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+// pnpNotification is the pseudo reader used to wait for reader attach/detach.
+const pnpNotification = `\\?PnP?\Notification`
+
+// statusSource is the part of a PC/SC context used to wait for readers and
+// cards. *scard.Context implements it; tests substitute a scripted fake.
+type statusSource interface {
+	ListReaders() ([]string, error)
+	GetStatusChange(states []scard.ReaderState, timeout time.Duration) error
+}
+
+// WaitForCard blocks until a card is present on the selected reader and
+// returns a connected device. Reader selection follows Open; when no reader
+// is attached yet, it also waits for one to appear. Cancelling ctx aborts
+// the wait.
+func WaitForCard(ctx context.Context, product Product, opts ...Option) (*Device, error) {
+	profile, err := ProfileByProduct(product)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := newOpenConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.requirePCSC("WaitForCard"); err != nil {
+		return nil, err
+	}
+	sctx, err := scard.EstablishContext()
+	if err != nil {
+		return nil, fmt.Errorf("establish pc/sc context: %w", err)
+	}
+
+	stop := cancelOnDone(ctx, sctx)
+	defer stop()
+
+	reader, err := waitCardPresent(ctx, sctx, cfg.selectors)
+	if err != nil {
+		sctx.Release()
+		return nil, err
+	}
+	t, err := connectSCardTransport(sctx, reader, cfg)
+	if err != nil {
+		return nil, err
+	}
+	d := newDevice(profile, t)
+	cfg.configure(d)
+	return d, nil
+}
+
+func waitCardPresent(ctx context.Context, sctx statusSource, selectors []ReaderSelector) (string, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		readers, err := listReaders(sctx)
+		if err != nil {
+			return "", err
+		}
+		if len(readers) == 0 {
+			if err := waitReaderChange(ctx, sctx, 0); err != nil {
+				return "", err
+			}
+			continue
+		}
+
+		known, err := queryReaderStates(sctx, readers)
+		if err != nil {
+			return "", err
+		}
+		reader, err := resolveReaderState(known, selectors)
+		if err != nil {
+			// The wanted reader may not be attached yet, or the selector
+			// depends on a card that has not been placed.
+			if err := waitAnyReaderChange(ctx, sctx, known); err != nil {
+				return "", err
+			}
+			continue
+		}
+		states := []scard.ReaderState{{Reader: reader, CurrentState: scard.StateUnaware}}
+		for {
+			if err := sctx.GetStatusChange(states, -1); err != nil {
+				return "", statusChangeError(ctx, err)
+			}
+			ev := states[0].EventState
+			if ev&(scard.StateUnknown|scard.StateUnavailable) != 0 {
+				// Reader went away; re-list and select again.
+				break
+			}
+			if cardReady(ev) {
+				return reader, nil
+			}
+			states[0].CurrentState = ev &^ scard.StateChanged
+		}
+	}
+}
+
+// cancelOnDone cancels blocking calls on sctx once ctx is done.
+// The returned stop function releases the watcher goroutine.
+func cancelOnDone(ctx context.Context, sctx *scard.Context) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			sctx.Cancel()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+// waitReaderChange blocks until the number of attached readers differs from count.
+func waitReaderChange(ctx context.Context, sctx statusSource, count int) error {
+	states := []scard.ReaderState{{
+		Reader:       pnpNotification,
+		CurrentState: scard.StateFlag(count << 16),
+	}}
+	if err := sctx.GetStatusChange(states, -1); err != nil {
+		return statusChangeError(ctx, err)
+	}
+	return nil
+}
+
+// waitAnyReaderChange blocks until a reader is attached or removed, or the
+// card state of one of readers changes.
+func waitAnyReaderChange(ctx context.Context, sctx statusSource, readers []readerState) error {
+	states := make([]scard.ReaderState, 0, len(readers)+1)
+	for _, r := range readers {
+		states = append(states, scard.ReaderState{Reader: r.Name, CurrentState: r.flags})
+	}
+	states = append(states, scard.ReaderState{
+		Reader:       pnpNotification,
+		CurrentState: scard.StateFlag(len(readers) << 16),
+	})
+	if err := sctx.GetStatusChange(states, -1); err != nil {
+		return statusChangeError(ctx, err)
+	}
+	return nil
+}
+
+// queryReaderStates reports the current card state of readers without blocking.
+func queryReaderStates(sctx statusSource, readers []string) ([]readerState, error) {
+	states := make([]scard.ReaderState, len(readers))
+	for i, r := range readers {
+		states[i] = scard.ReaderState{Reader: r, CurrentState: scard.StateUnaware}
+	}
+	if err := sctx.GetStatusChange(states, 0); err != nil && !errors.Is(err, scard.ErrTimeout) {
+		return nil, fmt.Errorf("get reader status: %w", err)
+	}
+	out := make([]readerState, len(states))
+	for i, st := range states {
+		flags := st.EventState &^ scard.StateChanged
+		out[i] = readerState{Name: st.Reader, Present: cardReady(flags), flags: flags}
+		if out[i].Present {
+			out[i].ATR = append([]byte(nil), st.Atr...)
+		}
+	}
+	return out, nil
+}
+
+func listReaders(sctx statusSource) ([]string, error) {
+	readers, err := sctx.ListReaders()
+	if errors.Is(err, scard.ErrNoReadersAvailable) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list readers: %w", err)
+	}
+	return readers, nil
+}
+
+func cardReady(flags scard.StateFlag) bool {
+	return flags&scard.StatePresent != 0 && flags&scard.StateMute == 0
+}
+
+func statusChangeError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return fmt.Errorf("wait status change: %w", err)
+}
@@ -0,0 +1,180 @@
+package ezsignnfc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+// fakeStatus is a scripted statusSource. A blocking GetStatusChange returns
+// at once when a reader differs from the caller's CurrentState; otherwise it
+// applies the next step as the awaited event. With no steps left it calls
+// cancel and fails like a cancelled PC/SC context.
+type fakeStatus struct {
+	readers []string
+	flags   map[string]scard.StateFlag
+	steps   []func(f *fakeStatus)
+	cancel  func()
+}
+
+func (f *fakeStatus) ListReaders() ([]string, error) {
+	if len(f.readers) == 0 {
+		return nil, scard.ErrNoReadersAvailable
+	}
+	return append([]string(nil), f.readers...), nil
+}
+
+func (f *fakeStatus) GetStatusChange(states []scard.ReaderState, timeout time.Duration) error {
+	for {
+		if f.fill(states) || timeout == 0 {
+			return nil
+		}
+		if len(f.steps) == 0 {
+			if f.cancel != nil {
+				f.cancel()
+			}
+			return scard.ErrCancelled
+		}
+		step := f.steps[0]
+		f.steps = f.steps[1:]
+		step(f)
+	}
+}
+
+// fill sets EventState for states and reports whether any of them changed.
+func (f *fakeStatus) fill(states []scard.ReaderState) bool {
+	changed := false
+	for i := range states {
+		st := &states[i]
+		var flags scard.StateFlag
+		switch {
+		case st.Reader == pnpNotification:
+			flags = scard.StateFlag(len(f.readers) << 16)
+		case f.attached(st.Reader):
+			flags = f.flags[st.Reader]
+		default:
+			flags = scard.StateUnknown
+		}
+		st.EventState = flags
+		st.Atr = nil
+		if flags&scard.StatePresent != 0 {
+			st.Atr = []byte{0x3B, 0x8F, 0x80, 0x01}
+		}
+		if flags != st.CurrentState {
+			st.EventState |= scard.StateChanged
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (f *fakeStatus) attached(reader string) bool {
+	for _, r := range f.readers {
+		if r == reader {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeStatus) attach(reader string, flags scard.StateFlag) {
+	f.readers = append(f.readers, reader)
+	f.flags[reader] = flags
+}
+
+func (f *fakeStatus) detach(reader string) {
+	for i, r := range f.readers {
+		if r == reader {
+			f.readers = append(f.readers[:i], f.readers[i+1:]...)
+			break
+		}
+	}
+	delete(f.flags, reader)
+}
+
+func TestWaitCardPresent(t *testing.T) {
+	cases := []struct {
+		name      string
+		readers   []string
+		selectors []ReaderSelector
+		steps     []func(f *fakeStatus)
+		want      string
+	}{
+		{
+			name: "no-reader",
+			steps: []func(f *fakeStatus){
+				func(f *fakeStatus) { f.attach("Reader A", scard.StatePresent) },
+			},
+			want: "Reader A",
+		},
+		{
+			// The selected reader is not attached yet, so selection fails
+			// until it shows up.
+			name:      "selector-retry",
+			readers:   []string{"Reader A"},
+			selectors: []ReaderSelector{ReaderName("Reader B")},
+			steps: []func(f *fakeStatus){
+				func(f *fakeStatus) { f.flags["Reader A"] = scard.StatePresent },
+				func(f *fakeStatus) { f.attach("Reader B", scard.StateEmpty) },
+				func(f *fakeStatus) { f.flags["Reader B"] = scard.StatePresent },
+			},
+			want: "Reader B",
+		},
+		{
+			name:      "card-selector-retry",
+			readers:   []string{"Reader A", "Reader B"},
+			selectors: []ReaderSelector{ReaderWithCard()},
+			steps: []func(f *fakeStatus){
+				func(f *fakeStatus) { f.flags["Reader B"] = scard.StatePresent },
+			},
+			want: "Reader B",
+		},
+		{
+			// The reader is unplugged while waiting for the card; the wait
+			// re-lists and selects it again once it is back.
+			name:    "reader-removed",
+			readers: []string{"Reader A"},
+			steps: []func(f *fakeStatus){
+				func(f *fakeStatus) { f.detach("Reader A") },
+				func(f *fakeStatus) { f.attach("Reader A", scard.StateEmpty) },
+				func(f *fakeStatus) { f.flags["Reader A"] = scard.StatePresent | scard.StateMute },
+				func(f *fakeStatus) { f.flags["Reader A"] = scard.StatePresent },
+			},
+			want: "Reader A",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := &fakeStatus{readers: tc.readers, flags: map[string]scard.StateFlag{}, steps: tc.steps}
+			for _, r := range tc.readers {
+				f.flags[r] = scard.StateEmpty
+			}
+			got, err := waitCardPresent(context.Background(), f, tc.selectors)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("reader: got %q want %q", got, tc.want)
+			}
+			if len(f.steps) != 0 {
+				t.Fatalf("%d steps left", len(f.steps))
+			}
+		})
+	}
+}
+
+func TestWaitCardPresentCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f := &fakeStatus{
+		readers: []string{"Reader A"},
+		flags:   map[string]scard.StateFlag{"Reader A": scard.StateEmpty},
+		cancel:  cancel,
+	}
+	if _, err := waitCardPresent(ctx, f, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
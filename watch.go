@@ -0,0 +1,207 @@
+package ezsignnfc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+// EventType classifies a reader or card change reported by a Watcher.
+type EventType int
+
+const (
+	ReaderAttached EventType = iota
+	ReaderRemoved
+	CardInserted
+	CardRemoved
+)
+
+func (t EventType) String() string {
+	switch t {
+	case ReaderAttached:
+		return "reader-attached"
+	case ReaderRemoved:
+		return "reader-removed"
+	case CardInserted:
+		return "card-inserted"
+	case CardRemoved:
+		return "card-removed"
+	default:
+		return fmt.Sprintf("EventType(%d)", int(t))
+	}
+}
+
+// Event is a single hot-plug change. ATR is set for card events.
+type Event struct {
+	Type   EventType
+	Reader string
+	ATR    []byte
+}
+
+// Watcher streams reader and card hot-plug events.
+type Watcher struct {
+	events chan Event
+	err    error
+}
+
+// WatchReaders starts watching PC/SC readers and cards until ctx is done.
+// The current readers and cards are reported first as attach/insert events.
+func WatchReaders(ctx context.Context) (*Watcher, error) {
+	sctx, err := scard.EstablishContext()
+	if err != nil {
+		return nil, fmt.Errorf("establish pc/sc context: %w", err)
+	}
+	w := &Watcher{events: make(chan Event, 16)}
+
+	stop := cancelOnDone(ctx, sctx)
+	go func() {
+		err := w.run(ctx, sctx)
+		stop()
+		sctx.Release()
+		if ctx.Err() == nil {
+			w.err = err
+		}
+		close(w.events)
+	}()
+	return w, nil
+}
+
+// Events returns the event channel. It is closed when watching stops.
+func (w *Watcher) Events() <-chan Event {
+	return w.events
+}
+
+// Err returns the error that stopped the watcher, or nil when it stopped
+// because its context was cancelled. It is valid after Events is closed.
+func (w *Watcher) Err() error {
+	return w.err
+}
+
+func (w *Watcher) run(ctx context.Context, sctx *scard.Context) error {
+	state := newWatchState()
+	pnp := true
+	for {
+		readers, err := listReaders(sctx)
+		if err != nil {
+			return err
+		}
+		if err := w.emit(ctx, state.setReaders(readers)); err != nil {
+			return err
+		}
+
+		states := make([]scard.ReaderState, 0, len(readers)+1)
+		for _, r := range readers {
+			states = append(states, scard.ReaderState{Reader: r, CurrentState: state.flags[r]})
+		}
+		if pnp {
+			states = append(states, scard.ReaderState{
+				Reader:       pnpNotification,
+				CurrentState: scard.StateFlag(len(readers) << 16),
+			})
+		}
+
+		timeout := time.Duration(-1)
+		if !pnp {
+			timeout = time.Second
+		}
+		err = sctx.GetStatusChange(states, timeout)
+		if errors.Is(err, scard.ErrTimeout) {
+			continue
+		}
+		if err != nil {
+			return statusChangeError(ctx, err)
+		}
+
+		for _, st := range states {
+			if st.Reader == pnpNotification {
+				if st.EventState&scard.StateUnknown != 0 {
+					// PnP notification unsupported; fall back to periodic re-listing.
+					pnp = false
+				}
+				continue
+			}
+			if err := w.emit(ctx, state.setFlags(st.Reader, st.EventState, st.Atr)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (w *Watcher) emit(ctx context.Context, events []Event) error {
+	for _, ev := range events {
+		select {
+		case w.events <- ev:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// watchState tracks known readers and card presence to turn PC/SC state
+// snapshots into edge events.
+type watchState struct {
+	flags   map[string]scard.StateFlag
+	present map[string][]byte
+}
+
+func newWatchState() *watchState {
+	return &watchState{
+		flags:   make(map[string]scard.StateFlag),
+		present: make(map[string][]byte),
+	}
+}
+
+func (s *watchState) setReaders(readers []string) []Event {
+	var events []Event
+	seen := make(map[string]bool, len(readers))
+	for _, r := range readers {
+		seen[r] = true
+		if _, ok := s.flags[r]; !ok {
+			s.flags[r] = scard.StateUnaware
+			events = append(events, Event{Type: ReaderAttached, Reader: r})
+		}
+	}
+	for r := range s.flags {
+		if seen[r] {
+			continue
+		}
+		if atr, ok := s.present[r]; ok {
+			events = append(events, Event{Type: CardRemoved, Reader: r, ATR: atr})
+			delete(s.present, r)
+		}
+		delete(s.flags, r)
+		events = append(events, Event{Type: ReaderRemoved, Reader: r})
+	}
+	return events
+}
+
+func (s *watchState) setFlags(reader string, flags scard.StateFlag, atr []byte) []Event {
+	if _, ok := s.flags[reader]; !ok {
+		return nil
+	}
+	s.flags[reader] = flags &^ scard.StateChanged
+
+	prevATR, wasPresent := s.present[reader]
+	isPresent := cardReady(flags)
+	switch {
+	case isPresent && !wasPresent:
+		s.present[reader] = append([]byte(nil), atr...)
+		return []Event{{Type: CardInserted, Reader: reader, ATR: s.present[reader]}}
+	case !isPresent && wasPresent:
+		delete(s.present, reader)
+		return []Event{{Type: CardRemoved, Reader: reader, ATR: prevATR}}
+	case isPresent && !bytes.Equal(prevATR, atr):
+		// A different card was swapped in between two status changes.
+		s.present[reader] = append([]byte(nil), atr...)
+		return []Event{
+			{Type: CardRemoved, Reader: reader, ATR: prevATR},
+			{Type: CardInserted, Reader: reader, ATR: s.present[reader]},
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package ezsignnfc
+
+import (
+	"testing"
+
+	"github.com/ebfe/scard"
+)
+
+func TestWatchStateEvents(t *testing.T) {
+	s := newWatchState()
+	atr := []byte{0x3B, 0x8F, 0x80, 0x01}
+
+	expect := func(t *testing.T, got []Event, want ...Event) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("event count: got %v want %v", got, want)
+		}
+		for i := range want {
+			if got[i].Type != want[i].Type || got[i].Reader != want[i].Reader {
+				t.Fatalf("event %d: got %v/%q want %v/%q", i, got[i].Type, got[i].Reader, want[i].Type, want[i].Reader)
+			}
+		}
+	}
+
+	expect(t, s.setReaders([]string{"Reader A"}), Event{Type: ReaderAttached, Reader: "Reader A"})
+	expect(t, s.setReaders([]string{"Reader A"}))
+	expect(t, s.setFlags("Reader A", scard.StateChanged|scard.StateEmpty, nil))
+	expect(t, s.setFlags("Reader A", scard.StateChanged|scard.StatePresent, atr), Event{Type: CardInserted, Reader: "Reader A"})
+	expect(t, s.setFlags("Reader A", scard.StatePresent|scard.StateInuse, atr))
+	expect(t, s.setFlags("Reader A", scard.StateChanged|scard.StateEmpty, nil), Event{Type: CardRemoved, Reader: "Reader A"})
+	expect(t, s.setFlags("Reader A", scard.StatePresent, atr), Event{Type: CardInserted, Reader: "Reader A"})
+	if got := s.flags["Reader A"]; got&scard.StateChanged != 0 {
+		t.Fatalf("stored flags must drop StateChanged: %v", got)
+	}
+
+	expect(t, s.setReaders(nil),
+		Event{Type: CardRemoved, Reader: "Reader A"},
+		Event{Type: ReaderRemoved, Reader: "Reader A"},
+	)
+	expect(t, s.setFlags("Reader A", scard.StatePresent, atr))
+}